@@ -0,0 +1,226 @@
+// Command fj evaluates fj paths against a JSON document read from a file or standard input.
+//
+// Usage:
+//
+//	fj [flags] [path ...]
+//
+// Each path is evaluated against the document and its result is printed on its own line. When no
+// path is given, the whole document is printed. The exit status is 0 when every path exists, 1 when
+// at least one path does not exist, and 2 on usage, input or path syntax errors. Invalid JSON is
+// reported with the line and column of the error, and with the line number of the record in JSON
+// Lines input.
+//
+// Flags:
+//
+//	-f, --file <path>     read the JSON document from a file instead of standard input
+//	-r, --raw             print strings without quotes
+//	-p, --pretty          pretty-print objects and arrays
+//	-c, --color <style>   colorize the output with a style (e.g. "default", "dark", "neon")
+//	-l, --lines           treat the input as JSON Lines and evaluate the paths on every line
+//
+// Example:
+//
+//	cat assets/data.json | fj -p 'stock.#(price_2007>=50)#.symbol'
+//	fj -f assets/data.json -r bank.0.name bank.#
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sivaosorg/fj"
+	"github.com/sivaosorg/unify4g"
+)
+
+// styles maps the names accepted by the --color flag to the styles defined by the fj package.
+var styles = map[string]*unify4g.Style{
+	"default":         unify4g.TerminalStyle,
+	"dark":            fj.DarkStyle,
+	"neon":            fj.NeonStyle,
+	"pastel":          fj.PastelStyle,
+	"highcontrast":    fj.HighContrastStyle,
+	"vintage":         fj.VintageStyle,
+	"cyberpunk":       fj.CyberpunkStyle,
+	"ocean":           fj.OceanStyle,
+	"fiery":           fj.FieryStyle,
+	"galaxy":          fj.GalaxyStyle,
+	"sunset":          fj.SunsetStyle,
+	"jungle":          fj.JungleStyle,
+	"monochrome":      fj.MonochromeStyle,
+	"forest":          fj.ForestStyle,
+	"ice":             fj.IceStyle,
+	"retro":           fj.RetroStyle,
+	"autumn":          fj.AutumnStyle,
+	"gothic":          fj.GothicStyle,
+	"vaporwave":       fj.VaporWaveStyle,
+	"vampire":         fj.VampireStyle,
+	"carnival":        fj.CarnivalStyle,
+	"steampunk":       fj.SteampunkStyle,
+	"woodland":        fj.WoodlandStyle,
+	"candy":           fj.CandyStyle,
+	"twilight":        fj.TwilightStyle,
+	"earth":           fj.EarthStyle,
+	"electric":        fj.ElectricStyle,
+	"witchinghour":    fj.WitchingHourStyle,
+	"midnight":        fj.MidnightStyle,
+	"retrofuture":     fj.RetroFutureStyle,
+	"forestmist":      fj.ForestMistStyle,
+	"prism":           fj.PrismStyle,
+	"spring":          fj.SpringStyle,
+	"desert":          fj.DesertStyle,
+	"solarflare":      fj.SolarFlareStyle,
+	"icequeen":        fj.IceQueenStyle,
+	"forestgrove":     fj.ForestGroveStyle,
+	"autumnleaves":    fj.AutumnLeavesStyle,
+	"vapor":           fj.VaporStyle,
+	"sunsetboulevard": fj.SunsetBoulevardStyle,
+	"neoncity":        fj.NeonCityStyle,
+	"moonlitnight":    fj.MoonlitNightStyle,
+	"candyshop":       fj.CandyShopStyle,
+	"underwater":      fj.UnderwaterStyle,
+	"oceanbreeze":     fj.OceanBreezeStyle,
+	"candypop":        fj.CandyPopStyle,
+	"noir":            fj.NoirStyle,
+	"galactic":        fj.GalacticStyle,
+	"vintagepastel":   fj.VintagePastelStyle,
+	"vintagefilm":     fj.VintageFilmStyle,
+	"fireworks":       fj.FireworksStyle,
+	"arcticsnow":      fj.ArcticSnowStyle,
+	"electricvibe":    fj.ElectricVibeStyle,
+	"desertsunset":    fj.DesertSunsetStyle,
+	"pasteldream":     fj.PastelDreamStyle,
+	"tropicalvibe":    fj.TropicalVibeStyle,
+}
+
+// options holds the command-line flags.
+type options struct {
+	file   string // The file to read, or an empty string for standard input.
+	raw    bool   // Print strings without quotes.
+	pretty bool   // Pretty-print objects and arrays.
+	color  string // The name of the color style, or an empty string for plain output.
+	lines  bool   // Treat the input as JSON Lines.
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command with the given arguments and streams, and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var opts options
+	fs := flag.NewFlagSet("fj", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.file, "file", "", "read the JSON document from a file instead of standard input")
+	fs.StringVar(&opts.file, "f", "", "shorthand for --file")
+	fs.BoolVar(&opts.raw, "raw", false, "print strings without quotes")
+	fs.BoolVar(&opts.raw, "r", false, "shorthand for --raw")
+	fs.BoolVar(&opts.pretty, "pretty", false, "pretty-print objects and arrays")
+	fs.BoolVar(&opts.pretty, "p", false, "shorthand for --pretty")
+	fs.StringVar(&opts.color, "color", "", "colorize the output with a style: "+strings.Join(styleNames(), ", "))
+	fs.StringVar(&opts.color, "c", "", "shorthand for --color")
+	fs.BoolVar(&opts.lines, "lines", false, "treat the input as JSON Lines and evaluate the paths on every line")
+	fs.BoolVar(&opts.lines, "l", false, "shorthand for --lines")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: fj [flags] [path ...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	var style *unify4g.Style
+	if len(opts.color) > 0 {
+		var ok bool
+		if style, ok = styles[strings.ToLower(opts.color)]; !ok {
+			fmt.Fprintf(stderr, "fj: unknown color style %q\n", opts.color)
+			return 2
+		}
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"@this"}
+	}
+
+	status := 0
+	evaluate := func(ctx fj.Context) bool {
+		for _, path := range paths {
+			result := ctx.Get(path)
+			if result.IsError() {
+				fmt.Fprintln(stderr, result.ErrMessage())
+				status = 2
+				return false
+			}
+			if !result.Exists() {
+				status = 1
+				continue
+			}
+			fmt.Fprintln(stdout, format(result, opts, style))
+		}
+		return true
+	}
+
+	input := stdin
+	if len(opts.file) > 0 {
+		f, err := os.Open(opts.file)
+		if err != nil {
+			fmt.Fprintf(stderr, "fj: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		input = f
+	}
+	if opts.lines {
+		// every record is validated by ForeachLine, which reports the line of a malformed record.
+		err := fj.ForeachLine(input, func(_ int, ctx fj.Context) bool {
+			return evaluate(ctx)
+		})
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		return status
+	}
+	data, err := io.ReadAll(input)
+	if err != nil {
+		fmt.Fprintf(stderr, "fj: %v\n", err)
+		return 2
+	}
+	if err := fj.ValidateBytes(data); err != nil {
+		// the *SyntaxError names the line and column of the error.
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	evaluate(fj.ParseBytes(data))
+	return status
+}
+
+// format renders a result according to the output options.
+func format(ctx fj.Context, opts options, style *unify4g.Style) string {
+	if opts.raw && ctx.Kind() == fj.String {
+		return ctx.String()
+	}
+	out := strings.TrimSpace(ctx.Unprocessed())
+	if opts.pretty {
+		out = strings.TrimSpace(string(unify4g.Pretty([]byte(out))))
+	}
+	if style != nil {
+		out = string(unify4g.Color([]byte(out), style))
+	}
+	return out
+}
+
+// styleNames returns the sorted names accepted by the --color flag.
+func styleNames() []string {
+	names := make([]string, 0, len(styles))
+	for name := range styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
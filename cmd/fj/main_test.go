@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		input      string
+		wantOut    string
+		wantErr    string
+		wantStatus int
+	}{
+		{
+			name:    "paths",
+			args:    []string{"name", "tags.#"},
+			input:   `{"name":"John","tags":["a","b"]}`,
+			wantOut: "\"John\"\n2\n",
+		},
+		{
+			name:    "raw strings",
+			args:    []string{"-r", "name"},
+			input:   `{"name":"John"}`,
+			wantOut: "John\n",
+		},
+		{
+			name:    "whole document",
+			input:   " {\"a\":1}\n",
+			wantOut: "{\"a\":1}\n",
+		},
+		{
+			name:    "json lines",
+			args:    []string{"--lines", "a"},
+			input:   "{\"a\":1}\n{\"a\":2}",
+			wantOut: "1\n2\n",
+		},
+		{
+			name:       "missing path",
+			args:       []string{"name", "age"},
+			input:      `{"name":"John"}`,
+			wantOut:    "\"John\"\n",
+			wantStatus: 1,
+		},
+		{
+			name:       "malformed path",
+			args:       []string{"stock.#(price>"},
+			input:      `{"stock":[]}`,
+			wantStatus: 2,
+		},
+		{
+			name:       "invalid json",
+			input:      "{\n  \"a\": 1\n  \"b\": 2\n}",
+			wantErr:    "line 3, column 3",
+			wantStatus: 2,
+		},
+		{
+			name:       "invalid json line",
+			args:       []string{"--lines", "a"},
+			input:      "{\"a\":1}\n{\"a\":}\n{\"a\":3}",
+			wantOut:    "1\n",
+			wantErr:    "fj: line 2: fj: invalid JSON at line 1, column 6",
+			wantStatus: 2,
+		},
+		{
+			name:       "missing file",
+			args:       []string{"--file", "does-not-exist.json"},
+			wantStatus: 2,
+		},
+		{
+			name:       "unknown style",
+			args:       []string{"--color", "nope", "name"},
+			input:      `{"name":"John"}`,
+			wantStatus: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := run(tt.args, strings.NewReader(tt.input), &stdout, &stderr)
+			if status != tt.wantStatus {
+				t.Errorf("run() status = %d, want %d (stderr: %s)", status, tt.wantStatus, stderr.String())
+			}
+			if stdout.String() != tt.wantOut {
+				t.Errorf("run() output = %q, want %q", stdout.String(), tt.wantOut)
+			}
+			if !strings.Contains(stderr.String(), tt.wantErr) {
+				t.Errorf("run() errors = %q, want %q", stderr.String(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package fj
 
 import (
+	"errors"
 	"regexp"
+	"sync"
+	"sync/atomic"
 
 	"github.com/sivaosorg/unify4g"
 )
@@ -27,6 +30,30 @@ const (
 	JSON
 )
 
+const (
+	// Added is a constant representing a value that only exists in the second document of a `Diff`.
+	Added ChangeKind = iota
+	// Removed is a constant representing a value that only exists in the first document of a `Diff`.
+	Removed
+	// Changed is a constant representing a value that differs between the documents of a `Diff`,
+	// but has the same JSON type in both.
+	Changed
+	// TypeChanged is a constant representing a value whose JSON type differs between the documents
+	// of a `Diff` (e.g. a number replaced by a string). True and false are both booleans.
+	TypeChanged
+)
+
+const (
+	// DefaultMaxLineSize is the maximum size, in bytes, of a single line read by `ForeachLine`
+	// when `LineOptions.MaxLineSize` is not set.
+	DefaultMaxLineSize = 1 << 20
+
+	// maxQueryRegexps is the maximum number of patterns held by `queryRegexpCache`. Patterns
+	// compiled once the cache is full are not cached, so that paths built from user input cannot
+	// grow the cache without bound.
+	maxQueryRegexps = 256
+)
+
 var (
 	// DisableTransformers is a global flag that determines whether transformers should be applied
 	// when processing JSON values. If set to true, transformers will not be applied to the JSON values.
@@ -53,6 +80,18 @@ var (
 	// be trimmed or removed.
 	regexpDupSpaces = regexp.MustCompile(`\s+`)
 
+	// unmarshalFieldCache caches the fields of the struct types decoded by `Context.Unmarshal`,
+	// keyed by `reflect.Type`, so that struct tags are only parsed once per type.
+	unmarshalFieldCache sync.Map
+
+	// queryRegexpCache caches the valid regular expressions of the `=~` and `!~` query operators,
+	// keyed by pattern, so that a pattern is only compiled once however often it is evaluated.
+	// It holds at most `maxQueryRegexps` patterns, counted by `queryRegexpCount`.
+	queryRegexpCache sync.Map
+
+	// queryRegexpCount is the number of patterns stored in `queryRegexpCache`.
+	queryRegexpCount atomic.Int64
+
 	// defaultStyle defines the default styling rules for different JSON elements.
 	// Each style consists of a pair of ANSI escape codes: a start and end sequence.
 	// These styles are applied to highlight keys, strings, numbers, booleans, nulls,
@@ -81,6 +120,41 @@ var (
 	}
 )
 
+var (
+	// ErrEmptyPath is returned when a function that modifies a JSON document is called
+	// with an empty path.
+	ErrEmptyPath = errors.New("fj: path must not be empty")
+
+	// ErrUnsupportedPath is returned when a path cannot address a single location for writing,
+	// for example because it contains wildcards, queries, transformers, multi-selectors or pipes.
+	// Only keys, escaped keys, array indexes and the `-1` append index are supported.
+	ErrUnsupportedPath = errors.New("fj: path must only contain keys and array indexes")
+
+	// ErrInvalidJSON is returned when a raw JSON value passed to a function is not well-formed.
+	ErrInvalidJSON = errors.New("fj: invalid JSON")
+
+	// ErrUnaddressablePath is returned when the result of a path does not reference a value stored
+	// in the document, for example the result of a transformer, a literal or a multi-selector,
+	// or the root value itself.
+	ErrUnaddressablePath = errors.New("fj: path result is not addressable in the document")
+
+	// ErrNotExist is returned when a value is decoded from a path or a `Context` that does not exist.
+	ErrNotExist = errors.New("fj: value does not exist")
+
+	// ErrInvalidPatch is returned by `ApplyPatch` when a patch operation is malformed, for example
+	// because its "op" is unknown, or its "path", "from" or "value" member is missing.
+	ErrInvalidPatch = errors.New("fj: invalid patch operation")
+
+	// ErrTestFailed is returned by `ApplyPatch` when the value of a "test" operation does not match.
+	ErrTestFailed = errors.New("fj: test operation failed")
+
+	// ErrNotArray is returned by `ArrayStream.Next` when the streamed value is not a JSON array.
+	ErrNotArray = errors.New("fj: value is not an array")
+
+	// ErrLineTooLong is returned by `ForeachLine` when a line exceeds the maximum line size.
+	ErrLineTooLong = errors.New("fj: line exceeds the maximum line size")
+)
+
 var (
 	// DarkStyle uses darker tones for styling.
 	DarkStyle = &unify4g.Style{
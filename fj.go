@@ -1,8 +1,10 @@
 package fj
 
 import (
+	"fmt"
 	"io"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -267,113 +269,11 @@ func ParseBytes(json []byte) Context {
 //
 // Notes:
 //   - If the path is not found, the returned Context will reflect this with an empty or null value.
+//   - If the path is malformed (e.g. `stock.#(price>` or `{a,b`), the returned Context carries a `*PathError`,
+//     reported by `Context.IsError`, `Context.ErrMessage` and `Context.Err`. The error is reported even when the
+//     malformed path yields a partial result, such as the `[]` of a query with an invalid regular expression.
 func Get(json, path string) Context {
-	if len(path) > 1 {
-		if (path[0] == '@' && !DisableTransformers) || path[0] == '!' {
-			var ok bool
-			var cPath string
-			var cJson string
-			if path[0] == '@' && !DisableTransformers {
-				cPath, cJson, ok = adjustTransformer(json, path)
-			} else if path[0] == '!' {
-				cPath, cJson, ok = parseStaticSegment(path)
-			}
-			if ok {
-				path = cPath
-				if len(path) > 0 && (path[0] == '|' || path[0] == '.') {
-					res := Get(cJson, path[1:])
-					res.index = 0
-					res.indexes = nil
-					return res
-				}
-				return Parse(cJson)
-			}
-		}
-		if path[0] == '[' || path[0] == '{' {
-			kind := path[0] // using a sub-selector path
-			var ok bool
-			var subs []subSelector
-			subs, path, ok = analyzeSubSelectors(path)
-			if ok {
-				if len(path) == 0 || (path[0] == '|' || path[0] == '.') {
-					var b []byte
-					b = append(b, kind)
-					var i int
-					for _, sub := range subs {
-						res := Get(json, sub.path)
-						if res.Exists() {
-							if i > 0 {
-								b = append(b, ',')
-							}
-							if kind == '{' {
-								if len(sub.name) > 0 {
-									if sub.name[0] == '"' && IsValidJSON(sub.name) {
-										b = append(b, sub.name...)
-									} else {
-										b = appendJSON(b, sub.name)
-									}
-								} else {
-									last := lastSegment(sub.path)
-									if isValidName(last) {
-										b = appendJSON(b, last)
-									} else {
-										b = appendJSON(b, "_")
-									}
-								}
-								b = append(b, ':')
-							}
-							var raw string
-							if len(res.unprocessed) == 0 {
-								raw = res.String()
-								if len(raw) == 0 {
-									raw = "null"
-								}
-							} else {
-								raw = res.unprocessed
-							}
-							b = append(b, raw...)
-							i++
-						}
-					}
-					b = append(b, kind+2)
-					var res Context
-					res.unprocessed = string(b)
-					res.kind = JSON
-					if len(path) > 0 {
-						res = res.Get(path[1:])
-					}
-					res.index = 0
-					return res
-				}
-			}
-		}
-	}
-	var i int
-	var c = &parser{json: json}
-	if len(path) >= 2 && path[0] == '.' && path[1] == '.' {
-		c.lines = true
-		analyzeArray(c, 0, path[2:])
-	} else {
-		for ; i < len(c.json); i++ {
-			if c.json[i] == '{' {
-				i++
-				parseJSONObject(c, i, path)
-				break
-			}
-			if c.json[i] == '[' {
-				i++
-				analyzeArray(c, i, path)
-				break
-			}
-		}
-	}
-	if c.piped {
-		res := c.value.Get(c.pipe)
-		res.index = 0
-		return res
-	}
-	computeIndex(json, c)
-	return c.value
+	return withPathError(get(json, path, nil), path)
 }
 
 // GetMul searches json for multiple paths.
@@ -442,7 +342,7 @@ func GetMul(json string, path ...string) []Context {
 //	fmt.Println("Unprocessed:", context.unprocessed) // Output: `{"key": "value", "nested": {"innerKey": "innerValue"}}`
 //	fmt.Println("Strings:", context.strings)         // Output: `"innerValue"`
 func GetBytes(json []byte, path string) Context {
-	return getBytes(json, path)
+	return withPathError(getBytes(json, path, nil), path)
 }
 
 // GetMulBytes searches json for multiple paths in the provided JSON byte slice.
@@ -476,6 +376,857 @@ func GetMulBytes(json []byte, path ...string) []Context {
 	return ctx
 }
 
+// GetPointer searches for the value referenced by an RFC 6901 JSON Pointer, such as "/a/b/0".
+//
+// A JSON Pointer is a string of reference tokens, each prefixed by '/', where '~1' stands for '/'
+// and '~0' stands for '~' within a token. Each token selects an object member by its exact key, or
+// an array element by its decimal index. The empty pointer "" references the whole document.
+//
+// Parameters:
+//   - `json`: The JSON string to search.
+//   - `pointer`: The JSON Pointer of the value.
+//
+// Returns:
+//   - A `Context` containing the referenced value, with its `Index` in the original JSON so that
+//     `Context.Path` and `Context.Pointer` can be used on it. The `Context` does not exist if the
+//     value is not found, and carries a `*PathError` (see `Context.Err`) if the pointer is malformed.
+//
+// Example Usage:
+//
+//	json := `{"definitions":{"a/b":{"type":"string"}},"tags":["x","y"]}`
+//	GetPointer(json, "/definitions/a~1b/type").String() // "string"
+//	GetPointer(json, "/tags/1").String()                // "y"
+//	GetPointer(json, "/tags/-").Exists()                // false
+//
+// Notes:
+//   - Unlike fj paths, tokens are matched literally: '*', '?', '#', '@' and '.' have no special meaning.
+//   - Array indexes must not have leading zeros, and the "-" token (the position after the last
+//     element) never references an existing value.
+func GetPointer(json, pointer string) Context {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return Context{err: err}
+	}
+	return getPointer(json, tokens)
+}
+
+// GetPointerBytes searches for the value referenced by an RFC 6901 JSON Pointer in a JSON byte slice.
+//
+// This function behaves like `GetPointer`, but operates on JSON data in byte slice format. The
+// returned `Context` does not reference the byte slice, so it remains valid if the slice is modified.
+//
+// Parameters:
+//   - `json`: The JSON byte slice to search.
+//   - `pointer`: The JSON Pointer of the value.
+//
+// Returns:
+//   - A `Context` containing the referenced value (see `GetPointer`).
+//
+// Example Usage:
+//
+//	ctx := GetPointerBytes([]byte(`{"a":{"b":[10,20]}}`), "/a/b/0")
+//	// ctx.Int64(): 10
+func GetPointerBytes(json []byte, pointer string) Context {
+	return GetPointer(string(json), pointer)
+}
+
+// PointerToPath converts an RFC 6901 JSON Pointer into the equivalent fj path.
+//
+// Each reference token is unescaped ('~1' to '/', '~0' to '~') and the characters that have a
+// special meaning in fj paths are escaped with a backslash, as `Context.Path` does.
+//
+// Parameters:
+//   - `pointer`: The JSON Pointer to convert.
+//
+// Returns:
+//   - The fj path. The empty pointer, which references the whole document, is converted to "@this".
+//     A token such as "-1" is escaped (`\-1`), so that it is a key rather than an index counted from
+//     the end of an array.
+//   - A `*PathError` if the pointer is malformed, or `ErrUnsupportedPath` if it contains a token that
+//     fj paths cannot express with the same meaning: an empty token, the "-" token (the nonexistent
+//     element after the last one of an array, whereas the `-1` segment is the last element), or a
+//     number with leading zeros (which is not an array index, whereas fj reads "01" as index 1).
+//
+// Example Usage:
+//
+//	path, _ := PointerToPath("/definitions/a.b~1c/0") // `definitions.a\.b\/c.0`
+//	_, err := PointerToPath("/tags/-")                // ErrUnsupportedPath
+func PointerToPath(pointer string) (string, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return "", err
+	}
+	if len(tokens) == 0 {
+		return "@this", nil
+	}
+	var path []byte
+	for i, token := range tokens {
+		if len(token) == 0 || token == "-" || (len(token) > 1 && token[0] == '0' && strings.Trim(token, "0123456789") == "") {
+			return "", ErrUnsupportedPath
+		}
+		if i > 0 {
+			path = append(path, '.')
+		}
+		if token[0] == '-' && strings.Trim(token[1:], "0123456789") == "" {
+			// an unescaped `-N` segment selects an array element counted from the end.
+			path = append(path, '\\')
+		}
+		path = append(path, escapeUnsafeChars(token)...)
+	}
+	return string(path), nil
+}
+
+// PathToPointer converts a simple fj path into the equivalent RFC 6901 JSON Pointer.
+//
+// Each path segment is unescaped (e.g. `a\.b` to "a.b"), then '~' and '/' are escaped as '~0' and
+// '~1' respectively.
+//
+// Parameters:
+//   - `path`: The fj path to convert, made of keys and array indexes. The path "@this" is converted
+//     to the empty pointer.
+//
+// Returns:
+//   - The JSON Pointer.
+//   - `ErrUnsupportedPath` if the path contains wildcards, queries, transformers, multi-selectors,
+//     pipes or the `-1` segment (the last element of an array, or the append position for `Set`),
+//     which JSON Pointers cannot express.
+//
+// Example Usage:
+//
+//	pointer, _ := PathToPointer(`definitions.a\.b\/c.0`) // "/definitions/a.b~1c/0"
+func PathToPointer(path string) (string, error) {
+	if path == "@this" {
+		return "", nil
+	}
+	components, ok := splitPathComponents(path)
+	if !ok {
+		return "", ErrUnsupportedPath
+	}
+	var pointer strings.Builder
+	for _, component := range components {
+		if component.append {
+			return "", ErrUnsupportedPath
+		}
+		pointer.WriteByte('/')
+		pointer.WriteString(escapePointerToken(component.key))
+	}
+	return pointer.String(), nil
+}
+
+// JSONPath evaluates an RFC 9535 JSONPath expression, such as `$.store.book[?(@.price < 10)].title`,
+// and returns every selected value together with its normalized path.
+//
+// The expression is evaluated with fj's scanner directly on the JSON string, without decoding the
+// document. The following syntax is supported:
+//   - The root identifier `$`, and the current node identifier `@` within filters.
+//   - Child segments: `.name`, `.*`, and bracketed selectors such as `['name']`, `["name"]`, `[*]`,
+//     `[0]`, `[-1]`, `[1:5:2]` and `[?expr]`, where several selectors can be combined (e.g. `[0,'a']`).
+//   - Descendant segments: `..name`, `..*` and `..[selectors]`.
+//   - Filters with comparisons (`==`, `!=`, `<`, `<=`, `>`, `>=`) between literals and singular
+//     queries (e.g. `@.price`, `$.limit`), existence tests (e.g. `@.isbn`), `&&`, `||`, `!` and
+//     parentheses.
+//
+// Parameters:
+//   - `json`: The JSON string to query.
+//   - `expr`: The JSONPath expression.
+//
+// Returns:
+//   - A `Context` containing a JSON array of the selected values, in the order defined by RFC 9535.
+//     Its `Indexes` locate each value in `json`, so `Context.Paths` returns their fj paths.
+//     If the expression is malformed, the `Context` does not exist and carries a `*PathError`
+//     (see `Context.Err`).
+//   - The normalized path of each selected value (e.g. `$['store']['book'][0]['title']`).
+//
+// Example Usage:
+//
+//	json := `{"store":{"book":[{"title":"Sayings","price":8.95},{"title":"Sword","price":12.99}]}}`
+//	ctx, paths := JSONPath(json, `$.store.book[?(@.price < 10)].title`)
+//	// ctx.String(): ["Sayings"]
+//	// paths: ["$['store']['book'][0]['title']"]
+//	// ctx.Paths(json): ["store.book.0.title"]
+//
+// Notes:
+//   - Function extensions (e.g. `length()`, `match()`) are not supported, and are reported as a
+//     `*PathError`.
+//   - As in RFC 9535, `<` and `>` only compare numbers with numbers and strings with strings, while
+//     `==` compares arrays and objects by deep equality.
+func JSONPath(json, expr string) (Context, []string) {
+	q, err := parseJSONPath(expr)
+	if err != nil {
+		return Context{err: err}, nil
+	}
+	root := jsonPathNode{value: Parse(json), path: "$"}
+	if !root.value.Exists() {
+		return Context{}, nil
+	}
+	nodes := q.evaluate(root, root)
+	raw := make([]byte, 0, 2)
+	raw = append(raw, '[')
+	indexes := make([]int, len(nodes))
+	paths := make([]string, len(nodes))
+	for i, node := range nodes {
+		if i > 0 {
+			raw = append(raw, ',')
+		}
+		raw = append(raw, node.value.unprocessed...)
+		indexes[i] = node.value.index
+		paths[i] = node.path
+	}
+	raw = append(raw, ']')
+	ctx := Parse(string(raw))
+	ctx.indexes = indexes
+	return ctx, paths
+}
+
+// Compile parses an fj path once into a reusable `Path`.
+//
+// Evaluating a path with `Get` parses the path string on every call: the keys, queries (`#(...)`),
+// transformers (`@name:args`), literals (`!value`) and multi-selectors (`[...]`, `{...}`) are
+// analyzed again each time. A compiled Path performs this work once, which speeds up the repeated
+// evaluation of the same path against many documents, and reports syntax errors up front instead
+// of silently returning a non-existent `Context`.
+//
+// Parameters:
+//   - `path`: The fj path to compile.
+//
+// Returns:
+//   - A pointer to the compiled `Path`.
+//   - A `*PathError` describing the first syntax error found in the path, with its offset: an unbalanced
+//     query (e.g. `stock.#(price>`), an invalid literal after '!', an unknown transformer, an
+//     unbalanced multi-selector (e.g. `{a,b`), or an invalid slice (e.g. `[::0]`).
+//
+// Notes:
+//   - An unknown transformer or an invalid literal is only reported at the start of the path or of a
+//     sub-path, or after a pipe. After a dot, such a component is looked up as a key, as `Get` does
+//     (e.g. `item.@type` selects the "@type" key).
+//
+// Example Usage:
+//
+//	p, err := Compile(`stock.#(price_2007>=50)#.symbol`)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	for _, doc := range docs {
+//	    fmt.Println(p.Get(doc).String())
+//	}
+//
+// Notes:
+//   - A path component that starts with '@' is a transformer; escape the '@' (e.g. `\@type`) to
+//     select a key that starts with '@'. Likewise, a path that starts with '!' is a literal.
+//   - `Path.Get` returns the same results as `Get` for the same path. If `DisableTransformers` is
+//     changed after the path was compiled, the path is parsed again on each evaluation.
+func Compile(path string) (*Path, error) {
+	plan, err := compilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Path{path: path, plan: plan, transformers: !DisableTransformers}, nil
+}
+
+// ValidatePath checks the syntax of an fj path without evaluating it.
+//
+// This function is useful to lint paths stored outside the code, such as in configuration files,
+// when an application starts, so that a malformed path is reported immediately instead of being
+// indistinguishable from a missing key at evaluation time.
+//
+// Parameters:
+//   - `path`: The fj path to check.
+//
+// Returns:
+//   - nil if the path is well-formed, or a `*PathError` describing the first syntax error, with
+//     the same checks as `Compile`.
+//
+// Example Usage:
+//
+//	err := ValidatePath("stock.#(price>")
+//	// err: fj: unbalanced query at offset 6 in path "stock.#(price>"
+//	err = ValidatePath("stock.#(price>10)#.symbol")
+//	// err: nil
+func ValidatePath(path string) error {
+	_, err := compilePath(path)
+	return err
+}
+
+// MustCompile is like `Compile` but panics if the path cannot be compiled. It simplifies the
+// initialization of global variables holding compiled paths.
+//
+// Parameters:
+//   - `path`: The fj path to compile.
+//
+// Returns:
+//   - A pointer to the compiled `Path`.
+//
+// Example Usage:
+//
+//	var symbols = MustCompile(`stock.#.symbol`)
+func MustCompile(path string) *Path {
+	p, err := Compile(path)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Get evaluates the compiled path against the provided JSON string.
+//
+// Parameters:
+//   - `json`: A string containing the JSON data to search.
+//
+// Returns:
+//   - A `Context` holding the result, exactly as `Get(json, p.String())` would.
+//
+// Example Usage:
+//
+//	p := MustCompile("user.name.firstName")
+//	fmt.Println(p.Get(`{"user":{"name":{"firstName":"John"}}}`).String()) // John
+func (p *Path) Get(json string) Context {
+	if p.transformers == DisableTransformers {
+		return get(json, p.path, nil)
+	}
+	return get(json, p.path, p.plan)
+}
+
+// GetBytes evaluates the compiled path against the provided JSON byte slice.
+//
+// This method behaves like `Path.Get`, but operates on JSON data in byte slice format, with the
+// same memory guarantees as `GetBytes`.
+//
+// Parameters:
+//   - `json`: A byte slice containing the JSON data to search.
+//
+// Returns:
+//   - A `Context` holding the result.
+func (p *Path) GetBytes(json []byte) Context {
+	if p.transformers == DisableTransformers {
+		return getBytes(json, p.path, nil)
+	}
+	return getBytes(json, p.path, p.plan)
+}
+
+// String returns the source path string of the compiled path.
+func (p *Path) String() string {
+	return p.path
+}
+
+// Set replaces the value found at the specified path within the provided JSON string, creating
+// any missing intermediate objects or arrays along the way, and returns the updated JSON string.
+//
+// The path uses the same syntax as `Get` for addressing a single location:
+//   - Dot notation: "name.last" or "age" for direct key lookups.
+//   - Escaped keys: "fav\.movie" addresses the key "fav.movie".
+//   - Array indexing: "children.1" addresses the second item in the "children" array.
+//   - Append: "children.-1" appends a new item to the end of the "children" array.
+//
+// Unlike `Get`, where "-1" selects the last element and "-2" the one before it, `Set` only accepts "-1",
+// as the append position. Other negative indexes are rejected, since they are not keys of an array;
+// replace an element counted from the end by computing its index from the array length (`Get(json, "children.#")`).
+//
+// Wildcards, queries, transformers, multi-selectors and pipes cannot address a single location,
+// so paths containing them are rejected with `ErrUnsupportedPath`.
+//
+// Parameters:
+//   - `json`: A string containing the JSON data to modify. An empty string is treated as a
+//     document that does not exist yet, and a new object or array is created for it.
+//   - `path`: A string representing the location of the value to set.
+//   - `value`: The Go value to store. Strings, booleans, numbers, nil and `Context` values are
+//     encoded directly; any other value is encoded with `encoding/json`.
+//
+// Returns:
+//   - A string containing the updated JSON document.
+//   - An error if the path is empty or unsupported, or if the value cannot be encoded.
+//
+// Example Usage:
+//
+//	json := `{"user": {"name": "Alice"}, "tags": ["a", "b"]}`
+//	json, _ = Set(json, "user.name", "Bob")        // {"user": {"name": "Bob"}, "tags": ["a", "b"]}
+//	json, _ = Set(json, "user.age", 29)            // {"user": {"name": "Bob","age":29}, "tags": ["a", "b"]}
+//	json, _ = Set(json, "tags.-1", "c")            // {"user": {...}, "tags": ["a", "b","c"]}
+//	json, _ = Set(json, "address.city", "Boston")  // {...,"address":{"city":"Boston"}}
+//
+// Notes:
+//   - The value is spliced at the byte offsets reported by `Context.Index()`, so every byte of the
+//     document that is not replaced keeps its original formatting.
+//   - Setting an index past the end of an array pads the array with `null` values.
+//   - Numeric segments create arrays when an intermediate container is missing; escape the
+//     segment (e.g. "\1") to create an object key instead.
+func Set(json, path string, value interface{}) (string, error) {
+	raw, err := encodeJSONValue(value)
+	if err != nil {
+		return json, err
+	}
+	return setRaw(json, path, raw)
+}
+
+// SetRaw replaces the value found at the specified path within the provided JSON string with a raw
+// JSON value, creating any missing intermediate objects or arrays along the way.
+//
+// This function behaves like `Set`, except that the value is already encoded as JSON and is
+// inserted into the document as-is (after validation).
+//
+// Parameters:
+//   - `json`: A string containing the JSON data to modify.
+//   - `path`: A string representing the location of the value to set.
+//   - `value`: A string containing the raw JSON value to store (e.g. `{"id":1}`, `[1,2]`, `"text"`).
+//
+// Returns:
+//   - A string containing the updated JSON document.
+//   - An error if the path is empty or unsupported, or `ErrInvalidJSON` if `value` is not valid JSON.
+//
+// Example Usage:
+//
+//	json := `{"user": {"name": "Alice"}}`
+//	json, _ = SetRaw(json, "user.roles", `["admin","editor"]`)
+//	// json: {"user": {"name": "Alice","roles":["admin","editor"]}}
+func SetRaw(json, path, value string) (string, error) {
+	if !IsValidJSON(value) {
+		return json, ErrInvalidJSON
+	}
+	return setRaw(json, path, trim(value))
+}
+
+// SetBytes replaces the value found at the specified path within the provided JSON byte slice,
+// creating any missing intermediate objects or arrays along the way.
+//
+// This function behaves like `Set`, but operates on JSON data in byte slice format.
+//
+// Parameters:
+//   - `json`: A byte slice containing the JSON data to modify.
+//   - `path`: A string representing the location of the value to set.
+//   - `value`: The Go value to store.
+//
+// Returns:
+//   - A new byte slice containing the updated JSON document.
+//   - An error if the path is empty or unsupported, or if the value cannot be encoded.
+//
+// Example Usage:
+//
+//	json := []byte(`{"user": {"name": "Alice"}}`)
+//	json, _ = SetBytes(json, "user.active", true)
+//	// json: {"user": {"name": "Alice","active":true}}
+func SetBytes(json []byte, path string, value interface{}) ([]byte, error) {
+	s, err := Set(string(json), path, value)
+	if err != nil {
+		return json, err
+	}
+	return []byte(s), nil
+}
+
+// SetRawBytes replaces the value found at the specified path within the provided JSON byte slice
+// with a raw JSON value, creating any missing intermediate objects or arrays along the way.
+//
+// This function behaves like `SetRaw`, but operates on JSON data in byte slice format.
+//
+// Parameters:
+//   - `json`: A byte slice containing the JSON data to modify.
+//   - `path`: A string representing the location of the value to set.
+//   - `value`: A byte slice containing the raw JSON value to store.
+//
+// Returns:
+//   - A new byte slice containing the updated JSON document.
+//   - An error if the path is empty or unsupported, or `ErrInvalidJSON` if `value` is not valid JSON.
+//
+// Example Usage:
+//
+//	json := []byte(`{"user": {"name": "Alice"}}`)
+//	json, _ = SetRawBytes(json, "user.meta", []byte(`{"v":1}`))
+//	// json: {"user": {"name": "Alice","meta":{"v":1}}}
+func SetRawBytes(json []byte, path string, value []byte) ([]byte, error) {
+	s, err := SetRaw(string(json), path, string(value))
+	if err != nil {
+		return json, err
+	}
+	return []byte(s), nil
+}
+
+// Delete removes the value found at the specified path from the provided JSON string, along with
+// its key when the value is an object member.
+//
+// The path supports the full `Get` syntax for locating values, as long as every result references
+// a value stored in the document. When the path yields several results, for example with a query
+// such as `bank.#(isActive==false)#`, every matching element is removed.
+//
+// Parameters:
+//   - `json`: A string containing the JSON data to modify.
+//   - `path`: A string representing the location of the value(s) to remove.
+//
+// Returns:
+//   - A string containing the updated JSON document. When the path does not exist, the document is
+//     returned unchanged.
+//   - `ErrEmptyPath` if the path is empty, or `ErrUnaddressablePath` if a result is not stored in the
+//     document (e.g. the output of a transformer) or is the root value.
+//
+// Example Usage:
+//
+//	json := `{"name": "Alice", "password": "secret", "tags": ["a", "b", "c"]}`
+//	json, _ = Delete(json, "password") // {"name": "Alice", "tags": ["a", "b", "c"]}
+//	json, _ = Delete(json, "tags.1")   // {"name": "Alice", "tags": ["a", "c"]}
+//
+//	json = `{"users": [{"id": 1, "active": false}, {"id": 2, "active": true}]}`
+//	json, _ = Delete(json, "users.#(active==false)#")
+//	// json: {"users": [{"id": 2, "active": true}]}
+//
+// Notes:
+//   - The separating comma and the whitespace that follows the removed value are removed with it,
+//     so the remaining bytes of the document keep their original formatting.
+//   - Deleting a missing path is not an error, which makes it safe to strip optional fields.
+func Delete(json, path string) (string, error) {
+	if len(path) == 0 {
+		return json, ErrEmptyPath
+	}
+	return deleteAtContext(json, Get(json, path))
+}
+
+// DeleteBytes removes the value found at the specified path from the provided JSON byte slice,
+// along with its key when the value is an object member.
+//
+// This function behaves like `Delete`, but operates on JSON data in byte slice format.
+//
+// Parameters:
+//   - `json`: A byte slice containing the JSON data to modify.
+//   - `path`: A string representing the location of the value(s) to remove.
+//
+// Returns:
+//   - A new byte slice containing the updated JSON document.
+//   - An error under the same conditions as `Delete`.
+//
+// Example Usage:
+//
+//	json := []byte(`{"id": "u-1", "name": "Alice"}`)
+//	json, _ = DeleteBytes(json, "id")
+//	// json: {"name": "Alice"}
+func DeleteBytes(json []byte, path string) ([]byte, error) {
+	s, err := Delete(string(json), path)
+	if err != nil {
+		return json, err
+	}
+	return []byte(s), nil
+}
+
+// ApplyPatch applies an RFC 6902 JSON Patch document to a JSON document.
+//
+// The patch is a JSON array of operations, each an object with an "op" member ("add", "remove",
+// "replace", "move", "copy" or "test"), a "path" member holding the JSON Pointer of the target
+// location, and a "from" or "value" member depending on the operation. The operations are applied
+// in order directly on the raw JSON text: only the bytes of the affected values are changed, so the
+// formatting of the rest of the document is preserved.
+//
+// Parameters:
+//   - `doc`: The JSON document to patch.
+//   - `patch`: The JSON Patch document.
+//
+// Returns:
+//   - The patched JSON document.
+//   - An error if any operation fails, in which case the original document is returned unchanged:
+//     the patch is applied atomically. The error is a `*PatchError` naming the index and the name of
+//     the failed operation, which wraps `ErrTestFailed` for a failed "test", an error wrapping
+//     `ErrNotExist` for a missing location, `ErrInvalidPatch` for a malformed operation, or a
+//     `*PathError` for a malformed pointer. `ErrInvalidJSON` is returned if either document is not
+//     valid JSON.
+//
+// Example Usage:
+//
+//	doc := `{"name": "Alice", "tags": ["a", "b"]}`
+//	patched, err := ApplyPatch(doc, `[
+//	    {"op": "test", "path": "/name", "value": "Alice"},
+//	    {"op": "replace", "path": "/name", "value": "Bob"},
+//	    {"op": "add", "path": "/tags/1", "value": "x"},
+//	    {"op": "remove", "path": "/tags/0"}
+//	]`)
+//	// patched: {"name": "Bob", "tags": ["x", "b"]}
+//
+// Notes:
+//   - Values are compared by "test" with semantic equality: numbers by value, and objects regardless
+//     of the order of their keys.
+//   - Members added to an object are appended after its last member.
+func ApplyPatch(doc, patch string) (string, error) {
+	if !IsValidJSON(doc) || !IsValidJSON(patch) {
+		return doc, ErrInvalidJSON
+	}
+	operations := Parse(patch)
+	if !operations.IsArray() {
+		return doc, ErrInvalidPatch
+	}
+	result := doc
+	var err error
+	index := 0
+	operations.Foreach(func(_, operation Context) bool {
+		if result, err = applyPatchOperation(result, operation); err != nil {
+			err = &PatchError{Index: index, Op: operation.Get("op").String(), Err: err}
+			return false
+		}
+		index++
+		return true
+	})
+	if err != nil {
+		return doc, err
+	}
+	return result, nil
+}
+
+// ApplyPatchBytes applies an RFC 6902 JSON Patch document to a JSON document in byte slice format.
+//
+// This function behaves like `ApplyPatch`, but operates on JSON data in byte slice format.
+//
+// Parameters:
+//   - `doc`: The JSON document to patch.
+//   - `patch`: The JSON Patch document.
+//
+// Returns:
+//   - The patched JSON document, or the original document if an operation fails.
+//   - An error describing the failed operation (see `ApplyPatch`).
+//
+// Example Usage:
+//
+//	patched, err := ApplyPatchBytes([]byte(`{"a":1}`), []byte(`[{"op":"add","path":"/b","value":2}]`))
+//	// patched: {"a":1,"b":2}
+func ApplyPatchBytes(doc, patch []byte) ([]byte, error) {
+	s, err := ApplyPatch(string(doc), string(patch))
+	if err != nil {
+		return doc, err
+	}
+	return []byte(s), nil
+}
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to a JSON document.
+//
+// A merge patch describes changes with the same shape as the document: the members of a patch object
+// are merged recursively into the target object, a `null` member removes the member from the target,
+// and any other value (including arrays) replaces the target value. The patch is applied directly on
+// the raw JSON text: the members of the target keep their order and formatting, new members are
+// appended after the last member of their object, and untouched bytes are preserved.
+//
+// Parameters:
+//   - `target`: The JSON document to patch.
+//   - `patch`: The JSON Merge Patch document.
+//
+// Returns:
+//   - The patched JSON document.
+//   - `ErrInvalidJSON` if either document is not valid JSON, in which case the target is returned
+//     unchanged.
+//
+// Example Usage:
+//
+//	target := `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"]}`
+//	patched, _ := MergePatch(target, `{"title":"Hello!","author":{"familyName":null},"tags":["example"],"phoneNumber":"+01-123-456-7890"}`)
+//	// patched: {"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"phoneNumber":"+01-123-456-7890"}
+func MergePatch(target, patch string) (string, error) {
+	if !IsValidJSON(target) || !IsValidJSON(patch) {
+		return target, ErrInvalidJSON
+	}
+	return mergePatch(target, Parse(patch)), nil
+}
+
+// CreateMergePatch creates the RFC 7396 JSON Merge Patch that transforms one JSON document into
+// another, such that `MergePatch(original, patch)` produces a document equal to `modified`.
+//
+// Members that were removed are set to `null` in the patch, members that were added or changed hold
+// their new value, and objects present in both documents are compared recursively. Values are
+// compared with semantic equality, so numbers are compared by value (`1` equals `1.0`) and objects
+// regardless of the order of their keys.
+//
+// Parameters:
+//   - `original`: The original JSON document.
+//   - `modified`: The modified JSON document.
+//
+// Returns:
+//   - The merge patch, which is `{}` when both objects are equal. When either document is not an
+//     object, the patch is the modified document itself.
+//   - `ErrInvalidJSON` if either document is not valid JSON.
+//
+// Example Usage:
+//
+//	patch, _ := CreateMergePatch(`{"a":1,"b":{"c":2,"d":3},"e":[1]}`, `{"a":1,"b":{"c":4},"e":[1,2],"f":true}`)
+//	// patch: {"b":{"c":4,"d":null},"e":[1,2],"f":true}
+//
+// Notes:
+//   - As specified by RFC 7396, `null` values cannot be set by a merge patch: a member whose value is
+//     `null` in the modified document is removed when the patch is applied.
+func CreateMergePatch(original, modified string) (string, error) {
+	if !IsValidJSON(original) || !IsValidJSON(modified) {
+		return "", ErrInvalidJSON
+	}
+	return createMergePatch(Parse(original), Parse(modified)), nil
+}
+
+// Diff compares two JSON documents and returns the structural differences between them.
+//
+// Objects are compared member by member and arrays element by element, recursively, and each value
+// that was added, removed or changed is reported as a `Change` with its fj path, its kind, and its old
+// and new values. Numbers are compared by value (`1` equals `1.0`), strings after unescaping.
+//
+// Parameters:
+//   - `a`: The first (old) JSON document.
+//   - `b`: The second (new) JSON document.
+//
+// Returns:
+//   - The changes, in document order, or nil if the documents are equal. The `Old` and `New` values
+//     keep their index in `a` and `b` respectively, so `Context.Path` can be used on them.
+//
+// Example Usage:
+//
+//	changes := Diff(`{"name":"Alice","age":30,"tags":["a"]}`, `{"name":"Alice","age":"30","tags":["a","b"],"x":1}`)
+//	// changes:
+//	//   {Path: "age", Kind: TypeChanged, Old: 30, New: "30"}
+//	//   {Path: "tags.1", Kind: Added, New: "b"}
+//	//   {Path: "x", Kind: Added, New: 1}
+//
+// Notes:
+//   - An object whose keys appear in a different order is reported once, as a change of the whole
+//     object, even if some of its members also changed.
+//   - Use `DiffWithOptions` to ignore the order of keys, to match array elements by a key instead of
+//     their index, or to compare numbers with a tolerance.
+func Diff(a, b string) []Change {
+	return DiffWithOptions(a, b, DiffOptions{})
+}
+
+// DiffWithOptions compares two JSON documents, like `Diff`, with the comparison configured by
+// `DiffOptions`.
+//
+// Parameters:
+//   - `a`: The first (old) JSON document.
+//   - `b`: The second (new) JSON document.
+//   - `opts`: The options controlling the comparison of key order, arrays and numbers.
+//
+// Returns:
+//   - The changes, in document order, or nil if the documents are equal.
+//
+// Example Usage:
+//
+//	a := `{"bank":[{"email":"a@x.com","age":26},{"email":"b@x.com","age":36}]}`
+//	b := `{"bank":[{"email":"b@x.com","age":36},{"email":"a@x.com","age":27}]}`
+//	changes := DiffWithOptions(a, b, DiffOptions{ArrayKeys: map[string]string{"bank": "email"}})
+//	// changes: {Path: "bank.0.age", Kind: Changed, Old: 26, New: 27}
+//
+// Notes:
+//   - With `ArrayKeys`, an element whose key only exists in one document is reported as added or
+//     removed, and the order of the elements is ignored. The paths of removed and changed elements
+//     use their index in `a`, and the paths of added elements their index in `b`.
+func DiffWithOptions(a, b string, opts DiffOptions) []Change {
+	d := differ{opts: opts}
+	d.compare("", "", Parse(a), Parse(b))
+	return d.changes
+}
+
+// Unmarshal decodes the value found at the specified path within the provided JSON string into
+// the Go value pointed to by `v`.
+//
+// This function is a shorthand for `Get(json, path).Unmarshal(v)`; when `path` is empty, the whole
+// document is decoded. See `Context.Unmarshal` for the supported types and struct tags.
+//
+// Parameters:
+//   - `json`: A string containing the JSON data.
+//   - `path`: A string representing the location of the value to decode, or an empty string for the
+//     whole document.
+//   - `v`: A non-nil pointer to the Go value to populate.
+//
+// Returns:
+//   - An error if the path does not exist (`ErrNotExist`), if `v` is not a non-nil pointer, or if a
+//     JSON value cannot be stored in the corresponding Go value.
+//
+// Example Usage:
+//
+//	type Permission struct {
+//	    ID      string   `json:"permissionId"`
+//	    Name    string   `json:"permissionName"`
+//	    Actions []string `json:"allowedActions"`
+//	}
+//	var permissions []Permission
+//	err := Unmarshal(json, "user.roles.0.permissions", &permissions)
+func Unmarshal(json, path string, v interface{}) error {
+	if len(path) == 0 {
+		return Parse(json).Unmarshal(v)
+	}
+	ctx := Get(json, path)
+	if ctx.err != nil {
+		return ctx.err
+	}
+	if !ctx.Exists() {
+		return fmt.Errorf("fj: path %q: %w", path, ErrNotExist)
+	}
+	return ctx.Unmarshal(v)
+}
+
+// GetAs retrieves the value at the specified path within the provided JSON string and converts it
+// to the Go type `T` using strict conversion rules.
+//
+// Unlike the typed getters of `Context` (e.g. `Int64`, `String`), which silently fall back to a
+// zero value or coerce between types, GetAs reports an error when the value does not exist or when
+// its JSON type does not match the requested Go type.
+//
+// Parameters:
+//   - `json`: A string containing the JSON data.
+//   - `path`: A string representing the location of the value.
+//
+// Returns:
+//   - The converted value, or the zero value of `T` on failure.
+//   - A `*TypeError` naming the path and the actual `Type` of the value when it does not exist or
+//     cannot be converted. `errors.Is(err, ErrNotExist)` reports whether the value is missing.
+//
+// Example Usage:
+//
+//	json := `{"user": {"id": 12345, "name": "Alice", "tags": ["a", "b"]}}`
+//	id, err := GetAs[int64](json, "user.id")       // 12345, nil
+//	tags, err := GetAs[[]string](json, "user.tags") // ["a" "b"], nil
+//	_, err = GetAs[int64](json, "user.name")
+//	// err: fj: cannot convert String at path "user.name" to int64
+//	_, err = GetAs[string](json, "user.email")
+//	// err: fj: path "user.email" does not exist
+//
+// Notes:
+//   - See `As` for the conversion rules.
+func GetAs[T any](json, path string) (T, error) {
+	return convertAs[T](Get(json, path), path)
+}
+
+// GetBytesAs retrieves the value at the specified path within the provided JSON byte slice and
+// converts it to the Go type `T` using strict conversion rules.
+//
+// This function behaves like `GetAs`, but operates on JSON data in byte slice format.
+//
+// Parameters:
+//   - `json`: A byte slice containing the JSON data.
+//   - `path`: A string representing the location of the value.
+//
+// Returns:
+//   - The converted value, or the zero value of `T` on failure.
+//   - A `*TypeError` when the value does not exist or cannot be converted.
+//
+// Example Usage:
+//
+//	active, err := GetBytesAs[bool]([]byte(`{"active": true}`), "active") // true, nil
+func GetBytesAs[T any](json []byte, path string) (T, error) {
+	return convertAs[T](GetBytes(json, path), path)
+}
+
+// As converts the value held by the Context to the Go type `T` using strict conversion rules.
+//
+// The conversion rules are:
+//   - `string` accepts only JSON strings, and `bool` only `true` or `false`.
+//   - Integer types accept only integral JSON numbers that fit in the type (no `"42"` to 42,
+//     and no truncation of `1.5`); floating-point types accept any JSON number within range.
+//   - `Context` returns the value itself, and `interface{}` the result of `Context.Value`.
+//   - `null` converts only to pointers, maps, slices and interfaces, which are set to nil.
+//   - Any other type (structs, maps, slices, arrays, pointers, `time.Time`, ...) is decoded with
+//     the rules of `Context.Unmarshal`.
+//
+// Parameters:
+//   - `ctx`: The Context to convert.
+//
+// Returns:
+//   - The converted value, or the zero value of `T` on failure.
+//   - A `*TypeError` naming the actual `Type` of the value when it does not exist or cannot be
+//     converted.
+//
+// Example Usage:
+//
+//	ctx := Get(`{"price": 9.5, "qty": 3}`, "qty")
+//	qty, err := As[int](ctx) // 3, nil
+//	_, err = As[string](ctx)
+//	// err: fj: cannot convert Number to string
+func As[T any](ctx Context) (T, error) {
+	return convertAs[T](ctx, "")
+}
+
 // Foreach iterates through each line of JSON data in the JSON Lines format (http://jsonlines.org/),
 // and applies a provided iterator function to each line. This is useful for processing large JSON data
 // sets where each line is a separate JSON object, allowing for efficient parsing and handling of each object.
@@ -591,6 +1342,56 @@ func IsValidJSONBytes(json []byte) bool {
 	return ok
 }
 
+// Validate checks whether the provided string is valid JSON, and describes the first syntax error
+// when it is not.
+//
+// This function performs the same validation as `IsValidJSON`, using the same scanner, so valid
+// documents are checked at the same speed. When the document is invalid, it is scanned a second
+// time to locate the error and determine the token that was expected.
+//
+// Parameters:
+//   - `json`: A string containing the JSON data to validate.
+//
+// Returns:
+//   - nil if the JSON is valid, or a `*SyntaxError` with the byte offset, line, column, expected
+//     token, found character and a short excerpt around the failure. The error wraps
+//     `ErrInvalidJSON`, so `errors.Is(err, ErrInvalidJSON)` reports true.
+//
+// Example Usage:
+//
+//	err := Validate("{\n  \"name\": \"Alice\"\n  \"age\": 30\n}")
+//	// err: fj: invalid JSON at line 3, column 3 (offset 22): expected ',' or '}', found '"' near `  "age": 30`
+//
+//	var syntaxErr *SyntaxError
+//	if errors.As(err, &syntaxErr) {
+//	    fmt.Println(syntaxErr.Line, syntaxErr.Column) // 3 3
+//	}
+func Validate(json string) error {
+	return ValidateBytes(unsafeStringToBytes(json))
+}
+
+// ValidateBytes checks whether the provided byte slice is valid JSON, and describes the first
+// syntax error when it is not.
+//
+// This function behaves like `Validate`, but operates on JSON data in byte slice format.
+//
+// Parameters:
+//   - `json`: A byte slice containing the JSON data to validate.
+//
+// Returns:
+//   - nil if the JSON is valid, or a `*SyntaxError` describing the first syntax error.
+//
+// Example Usage:
+//
+//	err := ValidateBytes([]byte(`[1, 2,]`))
+//	// err: fj: invalid JSON at line 1, column 7 (offset 6): expected value, found ']' near `[1, 2,]`
+func ValidateBytes(json []byte) error {
+	if _, ok := verifyJSON(json, 0); ok {
+		return nil
+	}
+	return newSyntaxError(json)
+}
+
 // AddTransformer binds a custom transformer function to the fj syntax.
 //
 // This function allows users to register custom transformer functions that can be applied
@@ -1273,6 +2074,59 @@ func (ctx Context) Map() map[string]Context {
 	return e.operations
 }
 
+// Unmarshal decodes the JSON value held by the Context into the Go value pointed to by `v`.
+//
+// The raw JSON is walked with fj's own scanner, so no intermediate `map[string]interface{}` or
+// `[]interface{}` values are built. The supported Go types are:
+//   - structs, populated from JSON objects;
+//   - maps with string, integer or `encoding.TextUnmarshaler` keys, populated from JSON objects;
+//   - slices and arrays, populated from JSON arrays (a `[]byte` is decoded from a base64 string);
+//   - pointers, which are allocated as needed and set to nil for `null`;
+//   - strings, booleans, integers and floating-point numbers, from the matching JSON kinds;
+//   - `time.Time`, from an RFC 3339 string or from a number of seconds since the Unix epoch;
+//   - types implementing `UnmarshalJSON([]byte) error` or `encoding.TextUnmarshaler`;
+//   - `interface{}`, populated with the result of `Context.Value`.
+//
+// Struct fields are matched against the object keys by their `json:"name"` tag, or by their field
+// name when the tag is missing, preferring an exact match over a case-insensitive one. Fields tagged
+// with `json:"-"` are ignored. A field tagged with `fj:"path.to.field"` is instead populated with the
+// result of the fj path evaluated against the object being decoded, which makes it possible to
+// flatten nested values (or even to apply queries and transformers) into a single struct.
+//
+// Parameters:
+//   - `v`: A non-nil pointer to the Go value to populate.
+//
+// Returns:
+//   - `ErrNotExist` if the Context does not exist.
+//   - An error if `v` is not a non-nil pointer, or if a JSON value cannot be stored in the
+//     corresponding Go value. The error names the fj path of the offending value.
+//
+// Example Usage:
+//
+//	type User struct {
+//	    ID        string    `json:"id"`
+//	    FirstName string    `fj:"name.firstName"`
+//	    Roles     []string  `fj:"roles.#.roleName"`
+//	    CreatedAt time.Time `json:"createdAt"`
+//	}
+//	var user User
+//	err := Get(json, "user").Unmarshal(&user)
+//
+// Notes:
+//   - Object keys and struct fields that do not match are ignored, and `null` leaves non-pointer
+//     values untouched, as with `encoding/json`.
+//   - Numbers are decoded into integer fields only when they are integral and within range.
+func (ctx Context) Unmarshal(v interface{}) error {
+	if !ctx.Exists() {
+		return ErrNotExist
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("fj: Unmarshal requires a non-nil pointer, got %T", v)
+	}
+	return decodeContext(ctx, rv.Elem(), "")
+}
+
 // Foreach iterates through the values of a JSON object or array, applying the provided iterator function.
 //
 // If the `Context` represents a non existent value (Null or invalid JSON), no iteration occurs.
@@ -1408,7 +2262,13 @@ func (ctx Context) Foreach(iterator func(key, value Context) bool) {
 //   - The function adjusts the indices of the results (if any) to account for the original position of the `Context`
 //     in the JSON string.
 func (ctx Context) Get(path string) Context {
-	q := Get(ctx.unprocessed, path)
+	return withPathError(ctx.get(path, nil), path)
+}
+
+// get searches for a specified path within the JSON structure of the Context, using the
+// pre-parsed segments of `plan` when it is not nil (see `Context.Get`).
+func (ctx Context) get(path string, plan *pathPlan) Context {
+	q := get(ctx.unprocessed, path, plan)
 	if q.indexes != nil {
 		for i := 0; i < len(q.indexes); i++ {
 			q.indexes[i] += ctx.index
@@ -1416,6 +2276,9 @@ func (ctx Context) Get(path string) Context {
 	} else {
 		q.index += ctx.index
 	}
+	for i := range q.origins {
+		q.origins[i].index += ctx.index
+	}
 	return q
 }
 
@@ -1509,89 +2372,26 @@ func (ctx Context) GetMul(path ...string) []Context {
 //	// The path "employees.1.name.last" corresponds to the "Jane Smith" employee,
 //	// and the query specifically looks at the "last" name of that employee.
 func (ctx Context) Path(json string) string {
-	var path []byte
-	var components []string
-	i := ctx.index - 1
-	// Ensure the index is within bounds of the original JSON
-	if ctx.index+len(ctx.unprocessed) > len(json) {
-		// JSON cannot safely contain the Result.
-		goto fail
-	}
-	// Ensure that the unprocessed part matches the expected JSON structure
-	if !strings.HasPrefix(json[ctx.index:], ctx.unprocessed) {
-		// Result is not at the expected index in the JSON.
-		goto fail
-	}
-	// Traverse the JSON from the result's index to extract the path
-	for ; i >= 0; i-- {
-		if json[i] <= ' ' {
-			continue
-		}
-		if json[i] == ':' {
-			for ; i >= 0; i-- {
-				if json[i] != '"' {
-					continue
-				}
-				break
-			}
-			raw := reverseSquash(json[:i+1])
-			i = i - len(raw)
-			components = append(components, raw)
-			// Key obtained, now process the next component
-			raw = reverseSquash(json[:i+1])
-			i = i - len(raw)
-			i++ // Move index for next loop step
-		} else if json[i] == '{' {
-			// Encountered an open object, this is likely not a valid result
-			goto fail
-		} else if json[i] == ',' || json[i] == '[' {
-			// Inside an array, count the position of the element
-			var arrayIdx int
-			if json[i] == ',' {
-				arrayIdx++
-				i--
-			}
-			for ; i >= 0; i-- {
-				if json[i] == ':' {
-					// Unexpected colon indicates an object key
-					goto fail
-				} else if json[i] == ',' {
-					arrayIdx++
-				} else if json[i] == '[' {
-					components = append(components, strconv.Itoa(arrayIdx))
-					break
-				} else if json[i] == ']' || json[i] == '}' || json[i] == '"' {
-					raw := reverseSquash(json[:i+1])
-					i = i - len(raw) + 1
-				}
-			}
-		}
+	components, ok := locateComponents(ctx, json)
+	if !ok {
+		return ""
 	}
 	// If no components are found, return a default path for "this"
 	if len(components) == 0 {
 		if DisableTransformers {
-			goto fail
+			return ""
 		}
 		return "@this"
 	}
-	// Build the final path by appending each component
-	for i := len(components) - 1; i >= 0; i-- {
-		rawComplexity := Parse(components[i])
-		if !rawComplexity.Exists() {
-			goto fail
+	// Build the final path by appending each escaped component
+	var path []byte
+	for i, component := range components {
+		if i > 0 {
+			path = append(path, '.')
 		}
-		comp := escapeUnsafeChars(rawComplexity.String())
-		path = append(path, '.')
-		path = append(path, comp...)
-	}
-	// Remove the leading dot and return the final path
-	if len(path) > 0 {
-		path = path[1:]
+		path = append(path, escapeUnsafeChars(component)...)
 	}
 	return string(path)
-fail:
-	// Return an empty string if the path could not be determined
-	return ""
 }
 
 // Paths returns the original fj paths for a Result where the Result came
@@ -1633,6 +2433,9 @@ fail:
 //   - This function is useful for extracting the specific query paths for
 //     elements within a larger result array, providing a way to inspect or
 //     manipulate the paths of individual items.
+//   - The elements of a deep wildcard (`**`) result that are computed rather than
+//     found in the JSON, such as the lengths returned by `**.#`, are reported with
+//     the path that computes them (e.g. "tags.#").
 //
 // Example Usage:
 //
@@ -1654,7 +2457,11 @@ func (ctx Context) Paths(json string) []string {
 	}
 	paths := make([]string, 0, len(ctx.indexes))
 	ctx.Foreach(func(_, value Context) bool {
-		paths = append(paths, value.Path(json))
+		path := value.Path(json)
+		if k := len(paths); path == "" && k < len(ctx.origins) && ctx.origins[k].path != "" {
+			path = originPath(json, ctx.origins[k].index, ctx.origins[k].path)
+		}
+		paths = append(paths, path)
 		return true
 	})
 	if len(paths) != len(ctx.indexes) {
@@ -1663,6 +2470,43 @@ func (ctx Context) Paths(json string) []string {
 	return paths
 }
 
+// Pointer returns the RFC 6901 JSON Pointer of a Result, like `Path` returns its fj path. The Result
+// must come from a path that returns a single value stored in the document, such as the result of
+// `Get`, `GetPointer` or a query.
+//
+// Parameters:
+//   - `json`: The original JSON used when calling `Get`.
+//
+// Returns:
+//   - A string representing the JSON Pointer of the value, where '~' and '/' in keys are escaped as
+//     '~0' and '~1' respectively. The pointer of the root value is the empty string.
+//   - A boolean that is `false` if the pointer cannot be determined (e.g., due to the result being
+//     from a multi-path or a transformer), which distinguishes a failure from the root pointer.
+//
+// Example Usage:
+//
+//	json := `{"definitions":{"a/b":{"type":"string"}},"tags":["x","y"]}`
+//	ctx := Get(json, `definitions.a/b.type`)
+//	ctx.Pointer(json) // "/definitions/a~1b/type", true
+//
+//	ctx = Get(json, `tags.#(=="y")`)
+//	ctx.Pointer(json) // "/tags/1", true
+//
+//	ctx = Get(json, `tags|@reverse`)
+//	ctx.Pointer(json) // "", false
+func (ctx Context) Pointer(json string) (string, bool) {
+	components, ok := locateComponents(ctx, json)
+	if !ok {
+		return "", false
+	}
+	var pointer strings.Builder
+	for _, component := range components {
+		pointer.WriteByte('/')
+		pointer.WriteString(escapePointerToken(component))
+	}
+	return pointer.String(), true
+}
+
 // Less compares two Context values (tokens) and returns true if the first token is considered less than the second one.
 // It performs comparisons based on the type of the tokens and their respective values.
 // The comparison order follows: Null < False < Number < String < True < JSON.
@@ -1727,6 +2571,25 @@ func (ctx Context) IsError() bool {
 	return ctx.err != nil
 }
 
+// Err returns the error associated with the Context, or nil if there is none.
+//
+// Unlike `ErrMessage`, the error keeps its type, so it can be inspected with `errors.As`, for
+// example to retrieve the offset of a `*PathError` reported by `Get`.
+//
+// Example Usage:
+//
+//	ctx := Get(json, "stock.#(price>")
+//	var pathErr *PathError
+//	if errors.As(ctx.Err(), &pathErr) {
+//	    fmt.Println(pathErr.Offset, pathErr.Reason) // 6 unbalanced query
+//	}
+//
+// Returns:
+//   - error: The error associated with the Context, or nil.
+func (ctx Context) Err() error {
+	return ctx.err
+}
+
 // ErrMessage returns the error message if there is an error in the Context.
 //
 // If the Context has an error (i.e., `err` is not `nil`), this function returns
@@ -1971,6 +2834,95 @@ func (t Type) String() string {
 	}
 }
 
+// String returns a string representation of the ChangeKind: "added", "removed", "changed" or
+// "type-changed", or an empty string for an unknown kind.
+//
+// Example Usage:
+//
+//	fmt.Println(TypeChanged.String()) // Output: "type-changed"
+func (k ChangeKind) String() string {
+	switch k {
+	default:
+		return ""
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	case TypeChanged:
+		return "type-changed"
+	}
+}
+
+// Error returns a description of the JSON syntax error, including its location.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("fj: invalid JSON at line %d, column %d (offset %d): expected %s, found %s near `%s`",
+		e.Line, e.Column, e.Offset, e.Expected, e.Found, e.Excerpt)
+}
+
+// Unwrap returns `ErrInvalidJSON`, so that `errors.Is(err, ErrInvalidJSON)` reports true.
+func (e *SyntaxError) Unwrap() error {
+	return ErrInvalidJSON
+}
+
+// Error returns a description of the syntax error, including its offset within the path.
+func (e *PathError) Error() string {
+	return fmt.Sprintf("fj: %s at offset %d in path %q", e.Reason, e.Offset, e.Path)
+}
+
+// Error returns a description of the conversion failure, naming the path and the JSON type of
+// the value.
+func (e *TypeError) Error() string {
+	var b strings.Builder
+	b.WriteString("fj: ")
+	if e.Missing {
+		if len(e.Path) == 0 {
+			b.WriteString("value does not exist")
+		} else {
+			b.WriteString("path " + strconv.Quote(e.Path) + " does not exist")
+		}
+		return b.String()
+	}
+	b.WriteString("cannot convert " + e.Kind.String())
+	if len(e.Path) > 0 {
+		b.WriteString(" at path " + strconv.Quote(e.Path))
+	}
+	b.WriteString(" to " + e.Target.String())
+	if e.Err != nil {
+		b.WriteString(": " + e.Err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap returns `ErrNotExist` when the value does not exist, or the underlying decoding error.
+func (e *TypeError) Unwrap() error {
+	if e.Missing {
+		return ErrNotExist
+	}
+	return e.Err
+}
+
+// Error returns a description of the error, including the index and the name of the operation.
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("fj: patch operation %d (%s): %v", e.Index, e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error of the operation.
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// Error returns a description of the error, including its line number.
+func (e *LineError) Error() string {
+	return fmt.Sprintf("fj: line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error of the line.
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
 func init() {
 	jsonTransformers = map[string]func(json, arg string) string{
 		"trim":       transformTrim,
@@ -2001,5 +2953,14 @@ func init() {
 		"wc":         transformCountWords,
 		"padLeft":    transformPadLeft,
 		"padRight":   transformPadRight,
+		"sum":        transformSum,
+		"avg":        transformAvg,
+		"min":        transformMin,
+		"max":        transformMax,
+		"count":      transformCount,
+		"sort":       transformSort,
+		"unique":     transformUnique,
+		"distinctBy": transformDistinctBy,
+		"groupBy":    transformGroupBy,
 	}
 }
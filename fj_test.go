@@ -1 +1,1502 @@
 package fj
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		path    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "replace existing key",
+			json:  `{"name":"John","age":30}`,
+			path:  "age",
+			value: 31,
+			want:  `{"name":"John","age":31}`,
+		},
+		{
+			name:  "add missing key",
+			json:  `{"name":"John"}`,
+			path:  "city",
+			value: "Paris",
+			want:  `{"name":"John","city":"Paris"}`,
+		},
+		{
+			name:  "create intermediate objects",
+			json:  `{}`,
+			path:  "user.address.city",
+			value: "Paris",
+			want:  `{"user":{"address":{"city":"Paris"}}}`,
+		},
+		{
+			name:  "escaped key",
+			json:  `{"fav.movie":"Deer Hunter"}`,
+			path:  `fav\.movie`,
+			value: "Jaws",
+			want:  `{"fav.movie":"Jaws"}`,
+		},
+		{
+			name:  "replace array element",
+			json:  `{"tags":["a","b","c"]}`,
+			path:  "tags.1",
+			value: "x",
+			want:  `{"tags":["a","x","c"]}`,
+		},
+		{
+			name:  "append to array",
+			json:  `{"tags":["a"]}`,
+			path:  "tags.-1",
+			value: "b",
+			want:  `{"tags":["a","b"]}`,
+		},
+		{
+			name:  "pad array with nulls",
+			json:  `{"tags":[]}`,
+			path:  "tags.2",
+			value: true,
+			want:  `{"tags":[null,null,true]}`,
+		},
+		{
+			name:  "create array for numeric key",
+			json:  `{}`,
+			path:  "list.0.id",
+			value: 7,
+			want:  `{"list":[{"id":7}]}`,
+		},
+		{
+			name:  "preserve formatting",
+			json:  "{\n  \"a\": 1,\n  \"b\": 2\n}",
+			path:  "b",
+			value: []int{1, 2},
+			want:  "{\n  \"a\": 1,\n  \"b\": [1,2]\n}",
+		},
+		{
+			name:    "reject queries",
+			json:    `{"a":[1]}`,
+			path:    "a.#(==1)",
+			value:   1,
+			want:    `{"a":[1]}`,
+			wantErr: true,
+		},
+		{
+			name:    "reject empty path",
+			json:    `{}`,
+			path:    "",
+			value:   1,
+			want:    `{}`,
+			wantErr: true,
+		},
+		{
+			name:    "reject negative index other than -1",
+			json:    `{"a":[1,2,3]}`,
+			path:    "a.-2",
+			value:   9,
+			want:    `{"a":[1,2,3]}`,
+			wantErr: true,
+		},
+		{
+			name:    "key on array",
+			json:    `{"a":[1]}`,
+			path:    "a.b",
+			value:   1,
+			want:    `{"a":[1]}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Set(tt.json, tt.path, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Set() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetRaw(t *testing.T) {
+	// Test Case 1: Store a raw object
+	got, err := SetRaw(`{"a":1}`, "b", `{"c": [1, 2]}`)
+	if err != nil || got != `{"a":1,"b":{"c": [1, 2]}}` {
+		t.Errorf("SetRaw() = %s, %v", got, err)
+	}
+
+	// Test Case 2: Reject invalid raw JSON
+	if _, err = SetRaw(`{"a":1}`, "b", `{"c":`); err != ErrInvalidJSON {
+		t.Errorf("SetRaw() error = %v, want %v", err, ErrInvalidJSON)
+	}
+
+	// Test Case 3: Bytes variant
+	b, err := SetBytes([]byte(`[1,2]`), "-1", 3)
+	if err != nil || string(b) != `[1,2,3]` {
+		t.Errorf("SetBytes() = %s, %v", b, err)
+	}
+
+	// Test Case 4: Store a one-digit raw number
+	got, err = SetRaw(`{"a":1}`, "a", `5`)
+	if err != nil || got != `{"a":5}` {
+		t.Errorf("SetRaw() = %s, %v", got, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		path    string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "object member",
+			json: `{"name":"John","password":"secret","age":30}`,
+			path: "password",
+			want: `{"name":"John","age":30}`,
+		},
+		{
+			name: "last object member",
+			json: `{"name":"John","password":"secret"}`,
+			path: "password",
+			want: `{"name":"John"}`,
+		},
+		{
+			name: "only object member",
+			json: `{"user":{ "id": 1 }}`,
+			path: "user.id",
+			want: `{"user":{}}`,
+		},
+		{
+			name: "array element",
+			json: `[1, 2, 3]`,
+			path: "1",
+			want: `[1, 3]`,
+		},
+		{
+			name: "preserve formatting",
+			json: "{\n  \"a\": 1,\n  \"b\": 2,\n  \"c\": 3\n}",
+			path: "b",
+			want: "{\n  \"a\": 1,\n  \"c\": 3\n}",
+		},
+		{
+			name: "escaped key",
+			json: `{"a\"b":1,"fav.movie":"Jaws"}`,
+			path: `fav\.movie`,
+			want: `{"a\"b":1}`,
+		},
+		{
+			name: "every query match",
+			json: `{"bank":[{"id":1,"isActive":false},{"id":2,"isActive":true},{"id":3,"isActive":false}]}`,
+			path: "bank.#(isActive==false)#",
+			want: `{"bank":[{"id":2,"isActive":true}]}`,
+		},
+		{
+			name: "every nested member",
+			json: `{"bank":[{"id":1,"email":"a"},{"id":2},{"id":3,"email":"c"}]}`,
+			path: "bank.#.email",
+			want: `{"bank":[{"id":1},{"id":2},{"id":3}]}`,
+		},
+		{
+			name: "missing path",
+			json: `{"a":1}`,
+			path: "b",
+			want: `{"a":1}`,
+		},
+		{
+			name: "no query match",
+			json: `{"bank":[{"id":1,"isActive":true}]}`,
+			path: "bank.#(isActive==false)#",
+			want: `{"bank":[{"id":1,"isActive":true}]}`,
+		},
+		{
+			name:    "empty path",
+			json:    `{"a":1}`,
+			path:    "",
+			want:    `{"a":1}`,
+			wantErr: ErrEmptyPath,
+		},
+		{
+			name:    "transformer result",
+			json:    `{"a":[1,2]}`,
+			path:    "a.@reverse",
+			want:    `{"a":[1,2]}`,
+			wantErr: ErrUnaddressablePath,
+		},
+		{
+			name:    "root value",
+			json:    `{"a":1}`,
+			path:    "@this",
+			want:    `{"a":1}`,
+			wantErr: ErrUnaddressablePath,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Delete(tt.json, tt.path)
+			if err != tt.wantErr {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Delete() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+type unmarshalName struct {
+	First string `json:"firstName"`
+	Last  string `json:"lastName"`
+}
+
+type unmarshalAudit struct {
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type unmarshalUser struct {
+	unmarshalAudit
+	ID        int               `json:"id"`
+	Name      *unmarshalName    `json:"name"`
+	Email     string            // matched case-insensitively
+	FirstName string            `fj:"name.firstName"`
+	Roles     []string          `fj:"roles.#.roleName"`
+	Scores    map[string]uint8  `json:"scores"`
+	Labels    map[int]string    `json:"labels"`
+	Extra     interface{}       `json:"extra"`
+	Ignored   string            `json:"-"`
+	Optional  *string           `json:"optional"`
+	Pair      [2]float64        `json:"pair"`
+	Raw       map[string]string `json:"raw"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	json := `{"user":{"id":12345,"name":{"firstName":"John","lastName":"Doe"},"email":"john@example.com",
+		"roles":[{"roleName":"Admin"},{"roleName":"Editor"}],"scores":{"go":9,"js":7},"labels":{"1":"one"},
+		"extra":{"a":[1,true]},"Ignored":"x","optional":null,"pair":[1.5,2],"createdAt":"2025-01-01T10:00:00Z"}}`
+
+	// Test Case 1: Decode a struct with json and fj tags
+	var user unmarshalUser
+	if err := Unmarshal(json, "user", &user); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if user.ID != 12345 || user.Name == nil || user.Name.Last != "Doe" || user.Email != "john@example.com" {
+		t.Errorf("Unmarshal() = %+v", user)
+	}
+	if user.FirstName != "John" || len(user.Roles) != 2 || user.Roles[1] != "Editor" {
+		t.Errorf("Unmarshal() fj tags = %q, %q", user.FirstName, user.Roles)
+	}
+	if user.Scores["go"] != 9 || user.Labels[1] != "one" || user.Ignored != "" || user.Optional != nil {
+		t.Errorf("Unmarshal() maps = %+v", user)
+	}
+	if user.Pair != [2]float64{1.5, 2} || !user.CreatedAt.Equal(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unmarshal() pair = %v, createdAt = %v", user.Pair, user.CreatedAt)
+	}
+	if extra, ok := user.Extra.(map[string]interface{}); !ok || len(extra["a"].([]interface{})) != 2 {
+		t.Errorf("Unmarshal() extra = %#v", user.Extra)
+	}
+
+	// Test Case 2: Report the path of a type mismatch
+	var target struct {
+		Scores map[string]int8 `json:"scores"`
+	}
+	err := Unmarshal(`{"scores":{"go":1.5}}`, "", &target)
+	if err == nil || !strings.Contains(err.Error(), `"scores.go"`) {
+		t.Errorf("Unmarshal() error = %v, want the path scores.go", err)
+	}
+
+	// Test Case 3: Reject strings for numbers
+	var n int
+	if err = Parse(`"42"`).Unmarshal(&n); err == nil {
+		t.Errorf("Unmarshal() error = nil, want a type error")
+	}
+
+	// Test Case 4: Missing path and invalid target
+	if err = Unmarshal(json, "user.missing", &n); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Unmarshal() error = %v, want %v", err, ErrNotExist)
+	}
+	if err = Parse(`1`).Unmarshal(n); err == nil {
+		t.Errorf("Unmarshal() error = nil, want an error for a non-pointer")
+	}
+
+	// Test Case 5: Report integers that overflow their field
+	var limits struct {
+		Signed   int64  `json:"signed"`
+		Unsigned uint64 `json:"unsigned"`
+		Small    int8   `json:"small"`
+	}
+	overflows := map[string]string{
+		`{"signed":99999999999999999999}`:   `fj: number 99999999999999999999 overflows int64 at path "signed"`,
+		`{"signed":9223372036854775808}`:    `fj: number 9223372036854775808 overflows int64 at path "signed"`,
+		`{"unsigned":18446744073709551616}`: `fj: number 18446744073709551616 overflows uint64 at path "unsigned"`,
+		`{"small":300}`:                     `fj: number 300 overflows int8 at path "small"`,
+	}
+	for doc, want := range overflows {
+		if err = Unmarshal(doc, "", &limits); err == nil || err.Error() != want {
+			t.Errorf("Unmarshal(%s) error = %v, want %s", doc, err, want)
+		}
+	}
+	if err = Unmarshal(`{"signed":-9223372036854775808,"unsigned":18446744073709551615}`, "", &limits); err != nil ||
+		limits.Signed != math.MinInt64 || limits.Unsigned != math.MaxUint64 {
+		t.Errorf("Unmarshal() = %+v, %v", limits, err)
+	}
+}
+
+func TestGetAs(t *testing.T) {
+	json := `{"id":12345,"name":"Alice","price":9.5,"big":300,"active":true,"tags":["a","b"],"meta":null,"user":{"id":1}}`
+
+	// Test Case 1: Strict scalar conversions
+	if id, err := GetAs[int64](json, "id"); err != nil || id != 12345 {
+		t.Errorf("GetAs[int64]() = %v, %v", id, err)
+	}
+	if name, err := GetAs[string](json, "name"); err != nil || name != "Alice" {
+		t.Errorf("GetAs[string]() = %v, %v", name, err)
+	}
+	if active, err := GetAs[bool](json, "active"); err != nil || !active {
+		t.Errorf("GetAs[bool]() = %v, %v", active, err)
+	}
+	if tags, err := GetAs[[]string](json, "tags"); err != nil || len(tags) != 2 {
+		t.Errorf("GetAs[[]string]() = %v, %v", tags, err)
+	}
+	if meta, err := GetAs[*string](json, "meta"); err != nil || meta != nil {
+		t.Errorf("GetAs[*string]() = %v, %v", meta, err)
+	}
+	if ctx, err := GetAs[Context](json, "user"); err != nil || ctx.Get("id").Int64() != 1 {
+		t.Errorf("GetAs[Context]() = %v, %v", ctx, err)
+	}
+
+	// Test Case 2: Mismatches report the path and the actual type
+	huge := `{"a":99999999999999999999,"b":9223372036854775808,"c":18446744073709551616,"d":-9223372036854775808,"e":18446744073709551615}`
+	tests := []struct {
+		name string
+		fn   func() error
+		want string
+	}{
+		{"string to int", func() error { _, err := GetAs[int](json, "name"); return err }, `fj: cannot convert String at path "name" to int`},
+		{"fraction to int", func() error { _, err := GetAs[int](json, "price"); return err }, `fj: cannot convert Number at path "price" to int`},
+		{"overflow", func() error { _, err := GetAs[uint8](json, "big"); return err }, `fj: cannot convert Number at path "big" to uint8`},
+		{"int64 overflow", func() error { _, err := GetAs[int64](huge, "a"); return err }, `fj: cannot convert Number at path "a" to int64`},
+		{"int64 min overflow", func() error { _, err := GetAs[int64](huge, "b"); return err }, `fj: cannot convert Number at path "b" to int64`},
+		{"uint64 overflow", func() error { _, err := GetAs[uint64](huge, "c"); return err }, `fj: cannot convert Number at path "c" to uint64`},
+		{"number to string", func() error { _, err := GetAs[string](json, "id"); return err }, `fj: cannot convert Number at path "id" to string`},
+		{"null to string", func() error { _, err := GetAs[string](json, "meta"); return err }, `fj: cannot convert Null at path "meta" to string`},
+		{"missing", func() error { _, err := GetAs[string](json, "email"); return err }, `fj: path "email" does not exist`},
+		{"context", func() error { _, err := As[bool](Parse(`1`)); return err }, `fj: cannot convert Number to bool`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fn()
+			var typeErr *TypeError
+			if !errors.As(err, &typeErr) || err.Error() != tt.want {
+				t.Errorf("error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+
+	// Test Case 3: Missing values unwrap to ErrNotExist
+	if _, err := GetAs[int](json, "missing"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("GetAs() error = %v, want %v", err, ErrNotExist)
+	}
+
+	// Test Case 4: Integers at the limits of their types are converted exactly
+	if n, err := GetAs[int64](huge, "d"); err != nil || n != math.MinInt64 {
+		t.Errorf("GetAs[int64]() = %v, %v", n, err)
+	}
+	if n, err := GetAs[uint64](huge, "e"); err != nil || n != math.MaxUint64 {
+		t.Errorf("GetAs[uint64]() = %v, %v", n, err)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	json := `{"stock":[{"symbol":"MMM","price":95.85,"@type":"equity","@key":"k1","!odd":true},{"symbol":"AMZN","price":93.43},{"symbol":"F","price":8.37}],
+		"bank":[{"name":"Stark","age":26,"tags":["a","b"]},{"name":"Rachelle","age":20,"tags":["c"]}],"@type":"report"}`
+	paths := []string{
+		"stock.#",
+		"stock.1.symbol",
+		"stock.#.symbol",
+		`stock.#(price>=50)#.symbol`,
+		`stock.#(symbol=="F").price`,
+		`stock.#(price>=50)#|#`,
+		`bank.#(tags.#(=="c"))#.name`,
+		"st*ck.0.sym?ol",
+		"bank.#.name|@reverse|0",
+		`bank.0.@pretty:{"sort_keys": true}`,
+		`{"symbols":stock.#.symbol,"count":bank.#}`,
+		"[stock.0.symbol,bank.1.name]",
+		`!{"a":1}|a`,
+		`\@type`,
+		"stock.0.@type",
+		"stock.#.@key",
+		"stock.0.!odd",
+		"missing.key",
+	}
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			p, err := Compile(path)
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			if p.String() != path {
+				t.Errorf("Path.String() = %q, want %q", p.String(), path)
+			}
+			want := Get(json, path)
+			if strings.Contains(path, ".@") && !want.Exists() {
+				t.Errorf("Get() does not exist, want the value of the key")
+			}
+			got := p.Get(json)
+			if got.Unprocessed() != want.Unprocessed() || got.Index() != want.Index() || got.Exists() != want.Exists() {
+				t.Errorf("Path.Get() = %q (index %d), want %q (index %d)", got.Unprocessed(), got.Index(), want.Unprocessed(), want.Index())
+			}
+			if got = p.GetBytes([]byte(json)); got.Unprocessed() != want.Unprocessed() {
+				t.Errorf("Path.GetBytes() = %q, want %q", got.Unprocessed(), want.Unprocessed())
+			}
+		})
+	}
+
+	// Syntax errors are reported with their offset.
+	errs := map[string]string{
+		"stock.#(price>":      `fj: unbalanced query at offset 6 in path "stock.#(price>"`,
+		"{a,b":                `fj: unbalanced multi-selector at offset 0 in path "{a,b"`,
+		"!bogus.a":            `fj: invalid literal at offset 0 in path "!bogus.a"`,
+		"stock|@nope":         `fj: unknown transformer at offset 6 in path "stock|@nope"`,
+		"@nope.a":             `fj: unknown transformer at offset 0 in path "@nope.a"`,
+		"stock.#(a>1)#|@nope": `fj: unknown transformer at offset 14 in path "stock.#(a>1)#|@nope"`,
+	}
+	for path, want := range errs {
+		if _, err := Compile(path); err == nil || err.Error() != want {
+			t.Errorf("Compile(%q) error = %v, want %s", path, err, want)
+		}
+	}
+}
+
+func TestPathError(t *testing.T) {
+	json := `{"stock":[{"symbol":"MMM","price":95.85}],"!odd":1}`
+
+	// Test Case 1: Malformed paths are reported through the Context
+	ctx := Get(json, "stock.#(price>")
+	var pathErr *PathError
+	if ctx.Exists() || !ctx.IsError() || !errors.As(ctx.Err(), &pathErr) {
+		t.Fatalf("Get() = %v, err = %v, want a *PathError", ctx, ctx.Err())
+	}
+	if pathErr.Offset != 6 || pathErr.Reason != "unbalanced query" {
+		t.Errorf("PathError = %+v", pathErr)
+	}
+	if ctx.ErrMessage() != `fj: unbalanced query at offset 6 in path "stock.#(price>"` {
+		t.Errorf("ErrMessage() = %s", ctx.ErrMessage())
+	}
+
+	// Test Case 2: Missing keys are not errors
+	if ctx = Get(json, "stock.0.missing"); ctx.IsError() {
+		t.Errorf("Get() error = %v, want nil", ctx.Err())
+	}
+	if ctx = Parse(json).Get(`stock.#(price>100)#`); ctx.IsError() {
+		t.Errorf("Context.Get() error = %v, want nil", ctx.Err())
+	}
+	if ctx = GetBytes([]byte(json), "{symbol,price"); !ctx.IsError() {
+		t.Errorf("GetBytes() error = nil, want a *PathError")
+	}
+
+	// Test Case 3: Malformed paths that still yield a result
+	bank := `{"bank":[{"name":"Stark","phone":"+1 (943) 542-3591"}],"@type":"bank"}`
+	ctx = Get(bank, `bank.#(phone=~"(")#.name`)
+	if !errors.As(ctx.Err(), &pathErr) || pathErr.Reason != "invalid regular expression" || pathErr.Offset != 5 {
+		t.Errorf("Get() = %s, err = %v, want an invalid regular expression", ctx.Unprocessed(), ctx.Err())
+	}
+	for _, path := range []string{"bank.#(", "bank.#(name>", "bank|#("} {
+		if ctx = Get(bank, path); !errors.As(ctx.Err(), &pathErr) || pathErr.Reason != "unbalanced query" {
+			t.Errorf("Get(%q) = %s, err = %v, want an unbalanced query", path, ctx.Unprocessed(), ctx.Err())
+		}
+	}
+	if ctx = Get(bank, "@type"); ctx.String() != "bank" || ctx.IsError() {
+		t.Errorf("Get(%q) = %s, err = %v, want bank", "@type", ctx.Unprocessed(), ctx.Err())
+	}
+
+	// Test Case 4: ValidatePath
+	tests := map[string]string{
+		"stock.#(price>10)#.symbol": "",
+		`\!odd`:                     "",
+		"stock.#(price>":            "unbalanced query",
+		"stock.{symbol,price":       "unbalanced multi-selector",
+		"!maybe":                    "invalid literal",
+		"stock|@unknown":            "unknown transformer",
+		"stock.0.@key":              "",
+	}
+	for path, reason := range tests {
+		err := ValidatePath(path)
+		if reason == "" {
+			if err != nil {
+				t.Errorf("ValidatePath(%q) = %v, want nil", path, err)
+			}
+			continue
+		}
+		if !errors.As(err, &pathErr) || pathErr.Reason != reason {
+			t.Errorf("ValidatePath(%q) = %v, want %s", path, err, reason)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		json     string
+		offset   int
+		line     int
+		column   int
+		expected string
+		found    string
+	}{
+		{`{"name":"Alice","age":30}`, -1, 0, 0, "", ""},
+		{`1`, -1, 0, 0, "", ""},
+		{`0`, -1, 0, 0, "", ""},
+		{`-1`, -1, 0, 0, "", ""},
+		{`[1]`, -1, 0, 0, "", ""},
+		{` 7`, -1, 0, 0, "", ""},
+		{"{\n  \"name\": \"Alice\"\n  \"age\": 30\n}", 22, 3, 3, "',' or '}'", `'"'`},
+		{`[1, 2,]`, 6, 1, 7, "value", "']'"},
+		{`{"a" 1}`, 5, 1, 6, "':'", "'1'"},
+		{`{"a":tru}`, 8, 1, 9, `"true"`, "'}'"},
+		{`{"a":"b`, 7, 1, 8, `closing '"'`, "end of input"},
+		{`["\x"]`, 3, 1, 4, "escape character", "'x'"},
+		{`["\u12g4"]`, 6, 1, 7, "hexadecimal digit", "'g'"},
+		{`{"é":1,}`, 8, 1, 8, "object key", "'}'"},
+		{`{} []`, 3, 1, 4, "end of input", "'['"},
+		{`-x`, 1, 1, 2, "digit", "'x'"},
+		{``, 0, 1, 1, "value", "end of input"},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.json)
+		if tt.offset < 0 {
+			if err != nil {
+				t.Errorf("Validate(%q) = %v, want nil", tt.json, err)
+			}
+			if !IsValidJSON(tt.json) {
+				t.Errorf("IsValidJSON(%q) = false, want true", tt.json)
+			}
+			continue
+		}
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || !errors.Is(err, ErrInvalidJSON) {
+			t.Errorf("Validate(%q) = %v, want a *SyntaxError", tt.json, err)
+			continue
+		}
+		if syntaxErr.Offset != tt.offset || syntaxErr.Line != tt.line || syntaxErr.Column != tt.column ||
+			syntaxErr.Expected != tt.expected || syntaxErr.Found != tt.found {
+			t.Errorf("Validate(%q) = %+v", tt.json, syntaxErr)
+		}
+		if IsValidJSON(tt.json) {
+			t.Errorf("IsValidJSON(%q) = true, want false", tt.json)
+		}
+	}
+	err := ValidateBytes([]byte(`[1, 2,]`))
+	if err == nil || err.Error() != "fj: invalid JSON at line 1, column 7 (offset 6): expected value, found ']' near `[1, 2,]`" {
+		t.Errorf("ValidateBytes() = %v", err)
+	}
+}
+
+func TestForeachLine(t *testing.T) {
+	input := "{\"name\":\"Gilbert\",\"age\":61}\r\n\n{\"name\":\"Alexa\",\"age\":34}\n{\"name\":\"May\",\"age\":57}"
+
+	// Test Case 1: Every record is visited with its line number
+	var lines []int
+	var names []string
+	err := ForeachLine(strings.NewReader(input), func(lineNo int, ctx Context) bool {
+		lines = append(lines, lineNo)
+		names = append(names, ctx.Get("name").String())
+		return true
+	})
+	if err != nil || fmt.Sprint(lines) != "[1 3 4]" || strings.Join(names, ",") != "Gilbert,Alexa,May" {
+		t.Errorf("ForeachLine() = %v, lines = %v, names = %v", err, lines, names)
+	}
+
+	// Test Case 2: A path is applied to each record, and the callback can stop the iteration
+	var ages []int64
+	err = ForeachLineWithOptions(strings.NewReader(input), LineOptions{Path: "age"}, func(lineNo int, ctx Context) bool {
+		ages = append(ages, ctx.Int64())
+		return len(ages) < 2
+	})
+	if err != nil || fmt.Sprint(ages) != "[61 34]" {
+		t.Errorf("ForeachLineWithOptions() = %v, ages = %v", err, ages)
+	}
+
+	// Test Case 3: Malformed records report their line number, unless skipped
+	broken := "{\"name\":\"Gilbert\"}\n{\"name\" \"Alexa\"}\n{\"name\":\"May\"}\n"
+	err = ForeachLine(strings.NewReader(broken), func(lineNo int, ctx Context) bool { return true })
+	var lineErr *LineError
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &lineErr) || lineErr.Line != 2 || !errors.As(err, &syntaxErr) || syntaxErr.Offset != 8 {
+		t.Errorf("ForeachLine() = %v, want a *LineError on line 2", err)
+	}
+	count := 0
+	err = ForeachLineWithOptions(strings.NewReader(broken), LineOptions{SkipInvalid: true}, func(lineNo int, ctx Context) bool {
+		count++
+		return true
+	})
+	if err != nil || count != 2 {
+		t.Errorf("ForeachLineWithOptions() = %v, count = %d, want 2", err, count)
+	}
+
+	// Test Case 4: Lines longer than the maximum line size
+	long := "{\"a\":1}\n{\"name\":\"" + strings.Repeat("x", 64) + "\"}\n"
+	err = ForeachLineWithOptions(strings.NewReader(long), LineOptions{MaxLineSize: 32}, func(lineNo int, ctx Context) bool { return true })
+	if !errors.As(err, &lineErr) || lineErr.Line != 2 || !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("ForeachLineWithOptions() = %v, want ErrLineTooLong on line 2", err)
+	}
+
+	// Test Case 5: Malformed paths are reported before reading
+	err = ForeachLineWithOptions(strings.NewReader(input), LineOptions{Path: "#(age>"}, func(lineNo int, ctx Context) bool { return true })
+	var pathErr *PathError
+	if !errors.As(err, &pathErr) {
+		t.Errorf("ForeachLineWithOptions() = %v, want a *PathError", err)
+	}
+
+	// Test Case 6: Scalar records, including a single digit
+	var values []string
+	err = ForeachLine(strings.NewReader("5\n\"x\"\n-1\n0"), func(lineNo int, ctx Context) bool {
+		values = append(values, ctx.String())
+		return true
+	})
+	if err != nil || strings.Join(values, ",") != "5,x,-1,0" {
+		t.Errorf("ForeachLine() = %v, values = %v", err, values)
+	}
+}
+
+func TestArrayStream(t *testing.T) {
+	json := "[\n  {\"symbol\":\"MMM\",\"price\":95.85},\n  12345,\n  \"a\\u00e9b\",\n  [true,false,null]\n]  trailing"
+
+	// Test Case 1: Every element is read, even one byte at a time
+	for _, r := range []io.Reader{strings.NewReader(json), iotest.OneByteReader(strings.NewReader(json))} {
+		stream := NewArrayStream(r)
+		var values []string
+		for {
+			ctx, err := stream.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Next() error = %v", err)
+			}
+			if json[ctx.Index():ctx.Index()+len(ctx.Unprocessed())] != ctx.Unprocessed() {
+				t.Errorf("Index() = %d, does not locate %s", ctx.Index(), ctx.Unprocessed())
+			}
+			values = append(values, ctx.String())
+		}
+		if strings.Join(values, "|") != `{"symbol":"MMM","price":95.85}|12345|aéb|[true,false,null]` {
+			t.Errorf("values = %v", values)
+		}
+		if _, err := stream.Next(); err != io.EOF {
+			t.Errorf("Next() after the end = %v, want io.EOF", err)
+		}
+	}
+
+	// Test Case 2: The stream descends to the array of a path
+	doc := `{"meta":{"items":[0]},"data":{"total":2,"items":["x",{"id":1},{"id":2}]}}`
+	stream := NewArrayStreamPath(iotest.OneByteReader(strings.NewReader(doc)), "data.items.1")
+	if _, err := stream.Next(); err != ErrNotArray {
+		t.Errorf("Next() = %v, want ErrNotArray", err)
+	}
+	stream = NewArrayStreamPath(strings.NewReader(doc), "data.items")
+	var count int
+	for {
+		if _, err := stream.Next(); err != nil {
+			if err != io.EOF {
+				t.Errorf("Next() error = %v", err)
+			}
+			break
+		}
+		count++
+	}
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+
+	// Test Case 3: Strings with brackets and escaped quotes do not end a value early
+	tricky := `[{"s":"]}\"[{"},"\\",[["]"]],-0.5e+2]`
+	stream = NewArrayStream(iotest.OneByteReader(strings.NewReader(tricky)))
+	var raws []string
+	for {
+		ctx, err := stream.Next()
+		if err != nil {
+			if err != io.EOF {
+				t.Errorf("Next() error = %v", err)
+			}
+			break
+		}
+		raws = append(raws, ctx.Unprocessed())
+	}
+	if strings.Join(raws, "|") != `{"s":"]}\"[{"}|"\\"|[["]"]]|-0.5e+2` {
+		t.Errorf("values = %v", raws)
+	}
+
+	// Test Case 4: Errors
+	tests := []struct {
+		json   string
+		path   string
+		check  func(err error) bool
+		reason string
+	}{
+		{`[]`, "", func(err error) bool { return err == io.EOF }, "io.EOF"},
+		{`{"data":[]}`, "data.missing", func(err error) bool {
+			var e *TypeError
+			return errors.Is(err, ErrNotExist) && !errors.As(err, &e)
+		}, "ErrNotExist"},
+		{`[1]`, "#.id", func(err error) bool { return errors.Is(err, ErrUnsupportedPath) }, "ErrUnsupportedPath"},
+		{"[1,\n 2,\n 3 4]", "", func(err error) bool {
+			var e *SyntaxError
+			return errors.As(err, &e) && e.Offset == 11 && e.Line == 3 && e.Column == 4 && e.Expected == "',' or ']'"
+		}, "*SyntaxError at line 3, column 4"},
+		{`[1,2`, "", func(err error) bool {
+			var e *SyntaxError
+			return errors.As(err, &e) && e.Offset == 4 && e.Found == "end of input"
+		}, "*SyntaxError at end of input"},
+	}
+	for _, tt := range tests {
+		stream := NewArrayStreamPath(strings.NewReader(tt.json), tt.path)
+		var err error
+		for err == nil {
+			_, err = stream.Next()
+		}
+		if !tt.check(err) {
+			t.Errorf("Next(%q, %q) = %v, want %s", tt.json, tt.path, err, tt.reason)
+		}
+	}
+}
+
+func TestPointer(t *testing.T) {
+	json := `{"definitions":{"a/b":{"type":"string"},"m~n":[10,20,30]},"a.b":{"*":true},"":0,"tags":["x","y"]}`
+
+	// Test Case 1: GetPointer
+	tests := []struct {
+		pointer string
+		want    string
+	}{
+		{"/definitions/a~1b/type", `"string"`},
+		{"/definitions/m~0n/2", "30"},
+		{"/a.b/*", "true"},
+		{"/", "0"},
+		{"/tags/0", `"x"`},
+		{"/tags/01", ""},
+		{"/tags/-", ""},
+		{"/tags/2", ""},
+		{"/definitions/missing", ""},
+		{"", json},
+	}
+	for _, tt := range tests {
+		ctx := GetPointer(json, tt.pointer)
+		if ctx.Unprocessed() != tt.want {
+			t.Errorf("GetPointer(%q) = %s, want %s", tt.pointer, ctx.Unprocessed(), tt.want)
+			continue
+		}
+		if pointer, ok := ctx.Pointer(json); ctx.Exists() && (!ok || pointer != tt.pointer) {
+			t.Errorf("GetPointer(%q).Pointer() = %q, %t", tt.pointer, pointer, ok)
+		}
+	}
+	if ctx := GetPointerBytes([]byte(json), "/definitions/m~0n/1"); ctx.Int64() != 20 {
+		t.Errorf("GetPointerBytes() = %s, want 20", ctx.Unprocessed())
+	}
+	var pathErr *PathError
+	for _, pointer := range []string{"definitions", "/a~2b", "/a~"} {
+		if ctx := GetPointer(json, pointer); !errors.As(ctx.Err(), &pathErr) {
+			t.Errorf("GetPointer(%q) error = %v, want a *PathError", pointer, ctx.Err())
+		}
+	}
+
+	// Test Case 2: Conversions between pointers and paths
+	conversions := []struct {
+		pointer string
+		path    string
+	}{
+		{"/definitions/a~1b/type", `definitions.a\/b.type`},
+		{"/a.b/*", `a\.b.\*`},
+		{"/definitions/m~0n/0", `definitions.m\~n.0`},
+		{"", "@this"},
+	}
+	for _, tt := range conversions {
+		if path, err := PointerToPath(tt.pointer); err != nil || path != tt.path {
+			t.Errorf("PointerToPath(%q) = %q, %v, want %q", tt.pointer, path, err, tt.path)
+		}
+		if pointer, err := PathToPointer(tt.path); err != nil || pointer != tt.pointer {
+			t.Errorf("PathToPointer(%q) = %q, %v, want %q", tt.path, pointer, err, tt.pointer)
+		}
+		if Get(json, tt.path).Unprocessed() != GetPointer(json, tt.pointer).Unprocessed() {
+			t.Errorf("Get(%q) and GetPointer(%q) differ", tt.path, tt.pointer)
+		}
+	}
+	if _, err := PathToPointer("tags.#"); !errors.Is(err, ErrUnsupportedPath) {
+		t.Errorf("PathToPointer() error = %v, want ErrUnsupportedPath", err)
+	}
+	for _, pointer := range []string{"/", "/tags/-", "/tags/01", "/a/00"} {
+		if _, err := PointerToPath(pointer); !errors.Is(err, ErrUnsupportedPath) {
+			t.Errorf("PointerToPath(%q) error = %v, want ErrUnsupportedPath", pointer, err)
+		}
+	}
+	if path, err := PointerToPath("/a/0x"); err != nil || path != "a.0x" {
+		t.Errorf("PointerToPath() = %q, %v, want a.0x", path, err)
+	}
+	if _, err := PathToPointer("tags.-1"); !errors.Is(err, ErrUnsupportedPath) {
+		t.Errorf("PathToPointer() error = %v, want ErrUnsupportedPath", err)
+	}
+	// negative-integer tokens are keys, not indexes counted from the end
+	negative := `{"a":[1,2,3],"b":{"-1":"x"}}`
+	for pointer, want := range map[string]string{"/a/-1": "", "/b/-1": `"x"`} {
+		path, err := PointerToPath(pointer)
+		if err != nil || Get(negative, path).Unprocessed() != want || GetPointer(negative, pointer).Unprocessed() != want {
+			t.Errorf("PointerToPath(%q) = %q, %v, want a path to %s", pointer, path, err, want)
+		}
+		if back, err := PathToPointer(path); err != nil || back != pointer {
+			t.Errorf("PathToPointer(%q) = %q, %v, want %q", path, back, err, pointer)
+		}
+	}
+
+	// Test Case 3: Context.Pointer of query results
+	if pointer, ok := Get(json, `tags.#(=="y")`).Pointer(json); !ok || pointer != "/tags/1" {
+		t.Errorf("Pointer() = %q, %t, want /tags/1", pointer, ok)
+	}
+	if pointer, ok := Get(json, "@this").Pointer(json); !ok || pointer != "" {
+		t.Errorf("Pointer() = %q, %t, want the root pointer", pointer, ok)
+	}
+	if pointer, ok := Get(json, "tags|@reverse").Pointer(json); ok {
+		t.Errorf("Pointer() = %q, %t, want false", pointer, ok)
+	}
+}
+
+func TestJSONPath(t *testing.T) {
+	json := `{"store":{"book":[
+		{"category":"reference","author":"Nigel Rees","title":"Sayings of the Century","price":8.95},
+		{"category":"fiction","author":"Evelyn Waugh","title":"Sword of Honour","price":12.99},
+		{"category":"fiction","author":"Herman Melville","title":"Moby Dick","isbn":"0-553-21311-3","price":8.99},
+		{"category":"fiction","author":"J. R. R. Tolkien","title":"The Lord of the Rings","isbn":"0-395-19395-8","price":22.99}],
+		"bicycle":{"color":"red","price":399}},"limit":10,"it's":[1,[2]]}`
+
+	tests := []struct {
+		expr  string
+		want  string
+		paths string
+	}{
+		{`$.store.book[?(@.price < 10)].title`, `["Sayings of the Century","Moby Dick"]`,
+			`$['store']['book'][0]['title'],$['store']['book'][2]['title']`},
+		{`$.store.book[?@.price < $.limit && !(@.category == 'reference')].author`, `["Herman Melville"]`, ""},
+		{`$..book[?@.isbn || @.price > 20].title`, `["Moby Dick","The Lord of the Rings"]`, ""},
+		{`$.store.book[-1].title`, `["The Lord of the Rings"]`, `$['store']['book'][3]['title']`},
+		{`$.store.book[0:4:2].price`, `[8.95,8.99]`, ""},
+		{`$.store.book[::-1].price`, `[22.99,8.99,12.99,8.95]`, ""},
+		{`$.store.book[0,1]["price"]`, `[8.95,12.99]`, ""},
+		{`$..price`, `[8.95,12.99,8.99,22.99,399]`, ""},
+		{`$.store.*.color`, `["red"]`, `$['store']['bicycle']['color']`},
+		{`$['it\'s'][*]`, `[1,[2]]`, `$['it\'s'][0],$['it\'s'][1]`},
+		{`$..*[?@ == 2]`, `[2]`, `$['it\'s'][1][0]`},
+		{`$['it\'s'][?@[0] == 2]`, `[[2]]`, `$['it\'s'][1]`},
+		{`$.store.book[?@.missing == @.alsoMissing].price`, `[8.95,12.99,8.99,22.99]`, ""},
+		{`$.store.book[?@.price == 'cheap']`, `[]`, ""},
+		{`$.limit`, `[10]`, `$['limit']`},
+		{`$`, "", "$"},
+	}
+	for _, tt := range tests {
+		ctx, paths := JSONPath(json, tt.expr)
+		if ctx.IsError() {
+			t.Errorf("JSONPath(%q) error = %v", tt.expr, ctx.Err())
+			continue
+		}
+		if tt.want != "" && ctx.String() != tt.want {
+			t.Errorf("JSONPath(%q) = %s, want %s", tt.expr, ctx.String(), tt.want)
+		}
+		if tt.paths != "" && strings.Join(paths, ",") != tt.paths {
+			t.Errorf("JSONPath(%q) paths = %v, want %s", tt.expr, paths, tt.paths)
+		}
+	}
+
+	// Test Case 2: Indexes locate the results in the original JSON
+	ctx, _ := JSONPath(json, `$.store.book[?(@.price < 10)].title`)
+	if fjPaths := ctx.Paths(json); strings.Join(fjPaths, ",") != "store.book.0.title,store.book.2.title" {
+		t.Errorf("Paths() = %v", fjPaths)
+	}
+
+	// Test Case 3: Malformed expressions
+	for _, expr := range []string{`store.book`, `$.store[`, `$.book[?@.price <]`, `$[?length(@) > 1]`, `$[?@..a == 1]`, `$[01]`, `$.store.book[?!@.price < 10]`} {
+		var pathErr *PathError
+		if ctx, _ := JSONPath(json, expr); !errors.As(ctx.Err(), &pathErr) {
+			t.Errorf("JSONPath(%q) error = %v, want a *PathError", expr, ctx.Err())
+		}
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	doc := "{\n  \"name\": \"Alice\",\n  \"tags\": [\"a\", \"b\"],\n  \"meta\": {\"a/b\": 1, \"n\": 1.0}\n}"
+
+	tests := []struct {
+		patch string
+		want  string
+	}{
+		{`[{"op":"replace","path":"/name","value":"Bob"}]`, "{\n  \"name\": \"Bob\",\n  \"tags\": [\"a\", \"b\"],\n  \"meta\": {\"a/b\": 1, \"n\": 1.0}\n}"},
+		{`[{"op":"add","path":"/tags/1","value":"x"},{"op":"add","path":"/tags/-","value":"z"}]`, "{\n  \"name\": \"Alice\",\n  \"tags\": [\"a\", \"x\",\"b\",\"z\"],\n  \"meta\": {\"a/b\": 1, \"n\": 1.0}\n}"},
+		{`[{"op":"remove","path":"/meta/a~1b"},{"op":"add","path":"/meta/c","value":[1]}]`, "{\n  \"name\": \"Alice\",\n  \"tags\": [\"a\", \"b\"],\n  \"meta\": {\"n\": 1.0,\"c\":[1]}\n}"},
+		{`[{"op":"move","from":"/name","path":"/meta/name"}]`, "{\n  \"tags\": [\"a\", \"b\"],\n  \"meta\": {\"a/b\": 1, \"n\": 1.0,\"name\":\"Alice\"}\n}"},
+		{`[{"op":"copy","from":"/tags","path":"/copy"},{"op":"test","path":"/copy","value":["a","b"]}]`, "{\n  \"name\": \"Alice\",\n  \"tags\": [\"a\", \"b\"],\n  \"meta\": {\"a/b\": 1, \"n\": 1.0},\"copy\":[\"a\", \"b\"]\n}"},
+		{`[{"op":"test","path":"/meta","value":{"n":1,"a/b":1e0}}]`, doc},
+		{`[{"op":"add","path":"","value":{"x":1}}]`, `{"x":1}`},
+	}
+	for _, tt := range tests {
+		patched, err := ApplyPatch(doc, tt.patch)
+		if err != nil || patched != tt.want {
+			t.Errorf("ApplyPatch(%s) = %q, %v, want %q", tt.patch, patched, err, tt.want)
+		}
+	}
+
+	// Test Case 2: Failed operations leave the document unchanged and name the operation
+	failures := []struct {
+		patch string
+		index int
+		op    string
+		err   error
+	}{
+		{`[{"op":"replace","path":"/name","value":"Bob"},{"op":"test","path":"/name","value":"Alice"}]`, 1, "test", ErrTestFailed},
+		{`[{"op":"remove","path":"/missing"}]`, 0, "remove", ErrNotExist},
+		{`[{"op":"add","path":"/tags/5","value":1}]`, 0, "add", ErrNotExist},
+		{`[{"op":"add","path":"/missing/a","value":1}]`, 0, "add", ErrNotExist},
+		{`[{"op":"replace","path":"/missing","value":1}]`, 0, "replace", ErrNotExist},
+		{`[{"op":"copy","from":"/missing","path":"/a"}]`, 0, "copy", ErrNotExist},
+		{`[{"op":"add","path":"/a"},{"op":"remove","path":"/name"}]`, 0, "add", ErrInvalidPatch},
+		{`[{"op":"move","from":"/meta","path":"/meta/x"}]`, 0, "move", ErrInvalidPatch},
+		{`[{"op":"rename","path":"/name"}]`, 0, "rename", ErrInvalidPatch},
+	}
+	for _, tt := range failures {
+		patched, err := ApplyPatch(doc, tt.patch)
+		var patchErr *PatchError
+		if patched != doc || !errors.As(err, &patchErr) || patchErr.Index != tt.index || patchErr.Op != tt.op || !errors.Is(err, tt.err) {
+			t.Errorf("ApplyPatch(%s) = %q, %v, want %v on operation %d", tt.patch, patched, err, tt.err, tt.index)
+		}
+		var typeErr *TypeError
+		if errors.As(err, &typeErr) {
+			t.Errorf("ApplyPatch(%s) error = %v, want no *TypeError", tt.patch, err)
+		}
+	}
+	if _, err := ApplyPatch(doc, `{"op":"remove"`); !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("ApplyPatch() error = %v, want ErrInvalidJSON", err)
+	}
+	if patched, err := ApplyPatchBytes([]byte(`{"a":1}`), []byte(`[{"op":"add","path":"/b","value":2}]`)); err != nil || string(patched) != `{"a":1,"b":2}` {
+		t.Errorf("ApplyPatchBytes() = %s, %v", patched, err)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// Test Case 1: The examples of RFC 7396, with the key order of the target preserved
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{"{\n  \"z\": 1,\n  \"a\": {\"x\": 1, \"y\": 2}\n}", `{"a":{"x":null,"w":3},"b":true}`, "{\n  \"z\": 1,\n  \"a\": {\"y\": 2,\"w\":3},\"b\":true\n}"},
+	}
+	for _, tt := range tests {
+		patched, err := MergePatch(tt.target, tt.patch)
+		if err != nil || patched != tt.want {
+			t.Errorf("MergePatch(%s, %s) = %q, %v, want %q", tt.target, tt.patch, patched, err, tt.want)
+		}
+	}
+	if _, err := MergePatch(`{"a":`, `{}`); !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("MergePatch() error = %v, want ErrInvalidJSON", err)
+	}
+
+	// Test Case 2: CreateMergePatch produces a patch that transforms the original into the modified document
+	creates := []struct {
+		original string
+		modified string
+		want     string
+	}{
+		{`{"a":1,"b":{"c":2,"d":3},"e":[1]}`, `{"a":1.0,"b":{"c":4},"e":[1,2],"f":true}`, `{"b":{"c":4,"d":null},"e":[1,2],"f":true}`},
+		{`{"a":{"x":1,"y":2}}`, `{"a":{"y":2,"x":1}}`, `{}`},
+		{`[1]`, `{"a":1}`, `{"a":1}`},
+		{`{"a":1}`, `[1]`, `[1]`},
+	}
+	for _, tt := range creates {
+		patch, err := CreateMergePatch(tt.original, tt.modified)
+		if err != nil || patch != tt.want {
+			t.Errorf("CreateMergePatch(%s, %s) = %s, %v, want %s", tt.original, tt.modified, patch, err, tt.want)
+			continue
+		}
+		if patched, _ := MergePatch(tt.original, patch); !jsonEqual(Parse(patched), Parse(tt.modified)) {
+			t.Errorf("MergePatch(%s, %s) = %s, want %s", tt.original, patch, patched, tt.modified)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	describe := func(changes []Change) string {
+		var parts []string
+		for _, c := range changes {
+			parts = append(parts, fmt.Sprintf("%s %s %s>%s", c.Kind, c.Path, c.Old.Unprocessed(), c.New.Unprocessed()))
+		}
+		return strings.Join(parts, "; ")
+	}
+	a := `{"name":"Alice","age":30,"active":true,"tags":["a","b"],"meta":{"x":1,"y":2},"n":1.0}`
+
+	tests := []struct {
+		b    string
+		opts DiffOptions
+		want string
+	}{
+		{a, DiffOptions{}, ""},
+		{`{"name":"Bob","age":"30","active":false,"tags":["a"],"meta":{"x":1,"y":2,"z":3},"n":1}`, DiffOptions{},
+			`changed name "Alice">"Bob"; type-changed age 30>"30"; changed active true>false; removed tags.1 "b">; added meta.z >3`},
+		{`{"name":"Alice","age":30,"active":true,"tags":["a","b"],"meta":{"y":2,"x":1},"n":1.0}`, DiffOptions{}, `changed meta {"x":1,"y":2}>{"y":2,"x":1}`},
+		{`{"name":"Alice","age":30,"active":true,"tags":["a","b"],"meta":{"y":3,"x":1},"n":1.0}`, DiffOptions{}, `changed meta {"x":1,"y":2}>{"y":3,"x":1}`},
+		{`{"name":"Alice","age":30,"active":true,"tags":["a","b"],"meta":{"y":3,"x":1},"n":1.0}`, DiffOptions{IgnoreKeyOrder: true}, `changed meta.y 2>3`},
+		{`{"meta":{"y":2,"x":1},"name":"Alice","age":30,"active":true,"tags":["a","b"],"n":1.0}`, DiffOptions{IgnoreKeyOrder: true}, ""},
+		{`{"name":"Alice","age":30.004,"active":true,"tags":["a","b"],"meta":{"x":1,"y":2}}`, DiffOptions{Tolerance: 0.01}, "removed n 1.0>"},
+		{`[1]`, DiffOptions{}, "type-changed @this " + a + ">[1]"},
+	}
+	for _, tt := range tests {
+		if got := describe(DiffWithOptions(a, tt.b, tt.opts)); got != tt.want {
+			t.Errorf("DiffWithOptions(%s) = %s, want %s", tt.b, got, tt.want)
+		}
+	}
+
+	// Test Case 2: Arrays matched by a key path
+	x := `{"bank":[{"email":"a@x.com","age":26},{"email":"b@x.com","age":36},{"email":"c@x.com","age":20}]}`
+	y := `{"bank":[{"email":"b@x.com","age":36},{"email":"a@x.com","age":27},{"email":"d@x.com","age":40}]}`
+	changes := DiffWithOptions(x, y, DiffOptions{ArrayKeys: map[string]string{"bank": "email"}})
+	want := `changed bank.0.age 26>27; removed bank.2 {"email":"c@x.com","age":20}>; added bank.2 >{"email":"d@x.com","age":40}`
+	if got := describe(changes); got != want {
+		t.Errorf("DiffWithOptions() = %s, want %s", got, want)
+	}
+	if len(changes) > 0 && (changes[0].Old.Path(x) != "bank.0.age" || changes[0].New.Path(y) != "bank.1.age") {
+		t.Errorf("Path() = %s, %s", changes[0].Old.Path(x), changes[0].New.Path(y))
+	}
+	nested := DiffWithOptions(`[{"roles":[{"id":1,"v":1},{"id":2}]}]`, `[{"roles":[{"id":2},{"id":1,"v":2}]}]`,
+		DiffOptions{ArrayKeys: map[string]string{"#.roles": "id"}})
+	if got := describe(nested); got != "changed 0.roles.0.v 1>2" {
+		t.Errorf("DiffWithOptions() = %s", got)
+	}
+	if Diff(`{"a":[1,2]}`, `{"a":[1,2]}`) != nil {
+		t.Errorf("Diff() of equal documents is not nil")
+	}
+	if Added.String() != "added" || TypeChanged.String() != "type-changed" {
+		t.Errorf("ChangeKind.String() = %s, %s", Added, TypeChanged)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	json := `{"bank":[
+		{"name":"Stark","gender":"male","age":26,"isActive":false},
+		{"name":"Rachelle","gender":"female","age":20,"isActive":false,"tags":["a"]},
+		{"name":"Davis","gender":"female","age":40,"isActive":true},
+		{"name":"Oneill","gender":"male","age":36,"isActive":true},
+		{"name":"Dalton","gender":"female","age":33,"isActive":false}]}`
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`bank.#(gender=="female" && (age<30 || isActive==true))#.name`, `["Rachelle","Davis"]`},
+		{`bank.#(gender=="male" || age>35)#.name`, `["Stark","Davis","Oneill"]`},
+		{`bank.#(gender=="female" && age>30 && isActive==false)#.name`, `["Dalton"]`},
+		{`bank.#(age<30 || age>35 && isActive==false)#.name`, `["Stark","Rachelle"]`},
+		{`bank.#(!(gender=="female"))#.name`, `["Stark","Oneill"]`},
+		{`bank.#(!tags && gender=="female")#.name`, `["Davis","Dalton"]`},
+		{`bank.#(!!tags)#.name`, `["Rachelle"]`},
+		{`bank.#(name=="A && B" || name!="Stark" && age==26)#.name`, `[]`},
+		{`bank.#((age>30) && isActive==true).name`, `"Davis"`},
+		{`bank.#(name!="Stark")#.name`, `["Rachelle","Davis","Oneill","Dalton"]`},
+	}
+	for _, tt := range tests {
+		if got := Get(json, tt.path).Unprocessed(); got != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+		p, err := Compile(tt.path)
+		if err != nil {
+			t.Errorf("Compile(%q) error = %v", tt.path, err)
+		} else if got := p.Get(json).Unprocessed(); got != tt.want {
+			t.Errorf("Compile(%q).Get() = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+
+	// Test Case 2: Regular expressions
+	phones := `[{"name":"Stark","phone":"+1 (943) 542-3591"},{"name":"Rollins","phone":"+1 (810) 521-2350"},
+		{"name":"Wade","phone":"+1 (836) 432-2542"},{"name":"Quote","phone":"\"8\""}]`
+	for path, want := range map[string]string{
+		`#(phone=~"^\+1 \(8\d\d\)")#.name`:           `["Rollins","Wade"]`,
+		`#(phone!~"^\+1 \(8\d\d\)")#.name`:           `["Stark","Quote"]`,
+		`#(phone=~"^\"8")#.name`:                     `["Quote"]`,
+		`#(name=~"(?i)^s" || phone=~"-2542$")#.name`: `["Stark","Wade"]`,
+		`#(name=~"[")#.name`:                         `[]`,
+		`#(name=~*)#.name`:                           `["Stark","Rollins","Wade","Quote"]`,
+	} {
+		if got := Get(phones, path).Unprocessed(); got != want {
+			t.Errorf("Get(%q) = %s, want %s", path, got, want)
+		}
+	}
+	if _, err := compileQueryRegexp(`^\d+$`); err != nil {
+		t.Errorf("compileQueryRegexp() error = %v", err)
+	} else if _, ok := queryRegexpCache.Load(`^\d+$`); !ok {
+		t.Errorf("compileQueryRegexp() did not cache the pattern")
+	}
+	if _, err := compileQueryRegexp(`(`); err == nil {
+		t.Errorf("compileQueryRegexp(%q) error = nil, want an error", `(`)
+	} else if _, ok := queryRegexpCache.Load(`(`); ok {
+		t.Errorf("compileQueryRegexp() cached an invalid pattern")
+	}
+	for i := 0; i < 2*maxQueryRegexps; i++ {
+		if _, err := compileQueryRegexp(fmt.Sprintf(`^x%d$`, i)); err != nil {
+			t.Errorf("compileQueryRegexp() error = %v", err)
+		}
+	}
+	if n := queryRegexpCount.Load(); n > maxQueryRegexps {
+		t.Errorf("queryRegexpCache holds %d patterns, want at most %d", n, maxQueryRegexps)
+	}
+
+	// Test Case 3: Comparisons with another field of the element
+	stock := `[{"symbol":"MMM","p07":95.85,"p02":56.27,"low":"a","high":"b"},{"symbol":"F","p07":8.37,"p02":9.63,"low":"b","high":"a"},
+		{"symbol":"GIS","p07":28.76,"p02":28.76,"low":"c","high":"c","flag":true,"on":true},{"symbol":"X","p07":1,"p02":{"v":0}}]`
+	for path, want := range map[string]string{
+		`#(p07>@.p02)#.symbol`:                `["MMM"]`,
+		`#(p07<=@.p02)#.symbol`:               `["F","GIS"]`,
+		`#(p07==@.p02 || low<@.high)#.symbol`: `["MMM","GIS"]`,
+		`#(flag==@.on)#.symbol`:               `["GIS"]`,
+		`#(p07>@.missing)#.symbol`:            `[]`,
+		`#(p07!=@.p02)#.symbol`:               `["MMM","F","X"]`,
+		`#(symbol=="@.low")#.symbol`:          `[]`,
+		`#(!(p07>@.p02) && p02>0)#.symbol`:    `["F","GIS"]`,
+		`#(low=="a" && high>@.low).symbol`:    `"MMM"`,
+		`#(p02.v==@.p02.v && p07==1)#.symbol`: `["X"]`,
+	} {
+		if got := Get(stock, path).Unprocessed(); got != want {
+			t.Errorf("Get(%q) = %s, want %s", path, got, want)
+		}
+	}
+
+	// Test Case 4: Membership and JSON literals
+	docs := `[{"n":1,"status":"active","tags":["a","b"],"m":{"a":1,"b":[2]},"o":["a","b"]},
+		{"n":2,"status":"pending","tags":["b","a"],"m":{"b":[2.0],"a":1}},{"n":3,"status":"closed","tags":"a","index":1}]`
+	for path, want := range map[string]string{
+		`#(status in ["active","pending"])#.n`:                    `[1,2]`,
+		`#(status !in ["active","pending"])#.n`:                   `[3]`,
+		`#(n in [1.0, 3e0])#.n`:                                   `[1,3]`,
+		`#(tags contains "a")#.n`:                                 `[1,2]`,
+		`#(tags contains "c")#.n`:                                 `[]`,
+		`#(tags==["a","b"])#.n`:                                   `[1]`,
+		`#(tags!=["a","b"])#.n`:                                   `[2,3]`,
+		`#(m=={"b":[2],"a":1.0})#.n`:                              `[1,2]`,
+		`#(tags==@.o)#.n`:                                         `[1]`,
+		`#(status in @.o || n>2 && tags contains "a")#.n`:         `[]`,
+		`#(index==1)#.n`:                                          `[3]`,
+		`#(status in ["x","closed"] && !(tags in [["a","b"]])).n`: `3`,
+	} {
+		if got := Get(docs, path).Unprocessed(); got != want {
+			t.Errorf("Get(%q) = %s, want %s", path, got, want)
+		}
+	}
+	// keys named after a word operator are still compared as paths
+	named := `[{"n":1,"in":"x","contains":5},{"n":2,"in":"y","contains":7}]`
+	for path, want := range map[string]string{
+		`#(in=="x").n`:          `1`,
+		`#(contains>4)#.n`:      `[1,2]`,
+		`#(in == "y").n`:        `2`,
+		`#(contains in [7])#.n`: `[2]`,
+	} {
+		if got := Get(named, path).Unprocessed(); got != want {
+			t.Errorf("Get(%q) = %s, want %s", path, got, want)
+		}
+	}
+	// without a path, word operators compare the elements themselves
+	scalars := `[1,2,"a",[2,3],[4],{"in":1}]`
+	for path, want := range map[string]string{
+		`#(in [1,"a"])#`:          `[1,"a"]`,
+		`#( in [2] )#`:            `[2]`,
+		`#(!in [1,"a"] && >1)#`:   `[2]`,
+		`#(contains 2)#`:          `[[2,3]]`,
+		`#(contains 2 || ==1)#`:   `[1,[2,3]]`,
+		`#(in [[4],{"in":1.0}])#`: `[[4],{"in":1}]`,
+	} {
+		if got := Get(scalars, path).Unprocessed(); got != want {
+			t.Errorf("Get(%q) = %s, want %s", path, got, want)
+		}
+		if p, err := Compile(path); err != nil {
+			t.Errorf("Compile(%q) error = %v", path, err)
+		} else if got := p.Get(scalars).Unprocessed(); got != want {
+			t.Errorf("Compile(%q).Get() = %s, want %s", path, got, want)
+		}
+	}
+
+	// Test Case 5: Malformed expressions
+	for _, path := range []string{`bank.#(age>30 &&)#`, `bank.#((age>30)#`, `bank.#(age>30 || (isActive==true)#`, `bank.#(!)#`,
+		`bank.#(name=~"[")#`, `bank.#(age>1 && name!~"(")#`, `bank.#(age>@.@bad)#`, `bank.#(eyeColor in "blue")#`} {
+		if err := ValidatePath(path); err == nil {
+			t.Errorf("ValidatePath(%q) error = nil", path)
+		}
+	}
+}
+
+func TestSlice(t *testing.T) {
+	json := `{"stock":[{"symbol":"MMM","price":95.85},{"symbol":"AMZN","price":93.43},{"symbol":"CPB","price":36.4},
+		{"symbol":"DIS","price":35.47},{"symbol":"DOW","price":44.67},{"symbol":"F","price":8.37}]}`
+
+	tests := []struct {
+		path  string
+		want  string
+		paths string
+	}{
+		{`stock.-1.symbol`, `"F"`, ""},
+		{`stock.-6.symbol`, `"MMM"`, ""},
+		{`stock.-7.symbol`, ``, ""},
+		{`stock.[2:4]`, `[{"symbol":"CPB","price":36.4},{"symbol":"DIS","price":35.47}]`, "stock.2,stock.3"},
+		{`stock.[2:4]|#.symbol`, `["CPB","DIS"]`, "stock.2.symbol,stock.3.symbol"},
+		{`stock.#.symbol|[::2]`, `["MMM","CPB","DOW"]`, "stock.0.symbol,stock.2.symbol,stock.4.symbol"},
+		{`stock.#.symbol|[-3:]`, `["DIS","DOW","F"]`, ""},
+		{`stock.#.symbol|[::-2]`, `["F","DIS","AMZN"]`, ""},
+		{`stock.#.symbol|[4:1:-1]`, `["DOW","DIS","CPB"]`, ""},
+		{`stock.#.symbol|[10:]`, `[]`, ""},
+		{`stock.#(price>=36)#|[0:3]|#.symbol`, `["MMM","AMZN","CPB"]`, "stock.0.symbol,stock.1.symbol,stock.2.symbol"},
+		{`stock.#(price<40)#.symbol|[1:]`, `["DIS","F"]`, "stock.3.symbol,stock.5.symbol"},
+		{`stock.[1:]|-1.symbol`, `"F"`, ""},
+		{`stock.0.symbol|[0:1]`, ``, ""},
+	}
+	for _, tt := range tests {
+		res := Get(json, tt.path)
+		if res.Unprocessed() != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.path, res.Unprocessed(), tt.want)
+		}
+		if tt.paths != "" && strings.Join(res.Paths(json), ",") != tt.paths {
+			t.Errorf("Get(%q).Paths() = %v, want %s", tt.path, res.Paths(json), tt.paths)
+		}
+		p, err := Compile(tt.path)
+		if err != nil {
+			t.Errorf("Compile(%q) error = %v", tt.path, err)
+		} else if got := p.Get(json).Unprocessed(); got != tt.want {
+			t.Errorf("Compile(%q).Get() = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+
+	// Test Case 2: A negative index locates the element in the original JSON
+	if path := Get(json, "stock.-2.price").Path(json); path != "stock.4.price" {
+		t.Errorf("Path() = %s, want stock.4.price", path)
+	}
+	if got := Get(`[1,2,3]`, `[1:]`).Unprocessed(); got != `[2,3]` {
+		t.Errorf("Get([1:]) = %s, want [2,3]", got)
+	}
+
+	// Test Case 3: Malformed slices and zero steps are path errors
+	for _, path := range []string{`stock.[a:b]`, `stock.[1:2:3:4]`, `stock.[::0]`, `stock.#.symbol|[1:x]`} {
+		var pathErr *PathError
+		if _, err := Compile(path); !errors.As(err, &pathErr) || pathErr.Reason != "invalid slice" {
+			t.Errorf("Compile(%q) error = %v, want an invalid slice", path, err)
+		}
+		if err := ValidatePath(path); !errors.As(err, &pathErr) || pathErr.Reason != "invalid slice" {
+			t.Errorf("ValidatePath(%q) = %v, want an invalid slice", path, err)
+		}
+		if res := Get(json, path); !errors.As(res.Err(), &pathErr) || pathErr.Reason != "invalid slice" {
+			t.Errorf("Get(%q) = %s, err = %v, want an invalid slice", path, res.Unprocessed(), res.Err())
+		}
+	}
+	if _, err := Compile(`[stock.0.symbol,stock.1.price]`); err != nil {
+		t.Errorf("Compile() error = %v, want nil for a multi-selector", err)
+	}
+}
+
+func TestDeepWildcard(t *testing.T) {
+	json := `{"email":"root@x.com","properties":{"alias":{"description":"Alias","type":"string"},
+		"attributes":{"description":"Attributes","properties":{"name":{"description":"Name"}}}},
+		"bank":[{"email":"a@x.com","friends":[{"email":"b@x.com"}]},{"email":"c@x.com","e.mail":"d"}],"nums":[[1,2],[3]]}`
+
+	tests := []struct {
+		path  string
+		want  string
+		paths string
+	}{
+		{`**.email`, `["root@x.com","a@x.com","b@x.com","c@x.com"]`, "email,bank.0.email,bank.0.friends.0.email,bank.1.email"},
+		{`properties.**.description`, `["Alias","Attributes","Name"]`,
+			"properties.alias.description,properties.attributes.description,properties.attributes.properties.name.description"},
+		{`bank.**.email|#`, `3`, ""},
+		{`bank.**.e?ail`, `["a@x.com","b@x.com","c@x.com"]`, ""},
+		{`**.friends.0.email`, `["b@x.com"]`, "bank.0.friends.0.email"},
+		{`**.0`, `[{"email":"a@x.com","friends":[{"email":"b@x.com"}]},{"email":"b@x.com"},[1,2],1,3]`, ""},
+		{`**.#(email=="c@x.com")#.e\.mail`, `[["d"]]`, ""},
+		{`nums.**`, `[[1,2],1,2,[3],3]`, "nums.0,nums.0.0,nums.0.1,nums.1,nums.1.0"},
+		{`**.#`, `[2,1,2,2,1]`, "bank.#,bank.0.friends.#,nums.#,nums.0.#,nums.1.#"},
+		{`**.#.email`, `[["a@x.com","c@x.com"],["b@x.com"]]`, "bank.#.email,bank.0.friends.#.email"},
+		{`**.friends.#.email`, `[["b@x.com"]]`, "bank.0.friends.#.email"},
+		{`**.#(email=="a@x.com")#.email`, `[["a@x.com"]]`, `bank.#(email=="a@x.com")#.email`},
+		{`**.**.email`, `["root@x.com","a@x.com","b@x.com","c@x.com"]`, "email,bank.0.email,bank.0.friends.0.email,bank.1.email"},
+		{`nums.**.**`, `[[1,2],1,2,[3],3]`, "nums.0,nums.0.0,nums.0.1,nums.1,nums.1.0"},
+		{`**.#(email=="b@x.com").email`, `["b@x.com"]`, "bank.0.friends.0.email"},
+		{`**.#(==3)`, `[3]`, "nums.1.0"},
+		{`**.#(>1)#|#`, `2`, ""},
+		{`**.e\.mail`, `["d"]`, `bank.1.e\.mail`},
+		{`**.missing`, `[]`, ""},
+		{`email.**.x`, `[]`, ""},
+	}
+	for _, tt := range tests {
+		res := Get(json, tt.path)
+		if res.Unprocessed() != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.path, res.Unprocessed(), tt.want)
+		}
+		if tt.paths != "" && strings.Join(res.Paths(json), ",") != tt.paths {
+			t.Errorf("Get(%q).Paths() = %v, want %s", tt.path, res.Paths(json), tt.paths)
+		}
+		p, err := Compile(tt.path)
+		if err != nil {
+			t.Errorf("Compile(%q) error = %v", tt.path, err)
+		} else if got := p.Get(json).Unprocessed(); got != tt.want {
+			t.Errorf("Compile(%q).Get() = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+
+	// Test Case 2: The paths of computed values evaluate to the values
+	res := Get(json, "**.#")
+	values := res.Array()
+	for k, path := range res.Paths(json) {
+		if got := Get(json, path).Unprocessed(); got != values[k].Unprocessed() {
+			t.Errorf("Get(%q) = %s, want %s", path, got, values[k].Unprocessed())
+		}
+	}
+	if paths := Parse(json).Get("bank").Get("**.#").Paths(json); strings.Join(paths, ",") != "bank.#,bank.0.friends.#" {
+		t.Errorf("Context.Get().Paths() = %v, want [bank.# bank.0.friends.#]", paths)
+	}
+}
+
+func TestAggregateTransformers(t *testing.T) {
+	json := `{"stock":[{"symbol":"MMM","price":95.85,"qty":2},{"symbol":"F","price":8.37,"qty":"n/a"},{"symbol":"GIS","price":28.76}],
+		"nums":[0.1,0.2,"x",null,3],"big":[1e2,2.5e1],"small":[1e-3,2e-3],"mixed":[0.5,1e-3],"empty":[],
+		"huge":[1e300,1e300],"tenths":[0.1,0.2,0.3],"tiny":[1e-9,2e-9]}`
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`stock.#.price|@sum`, `132.98`},
+		{`stock|@sum:{"path":"price"}`, `132.98`},
+		{`nums|@sum`, `3.3`},
+		{`nums|@sum:{"strict":true}`, ``},
+		{`big|@sum`, `125`},
+		{`small|@sum`, `0.003`},
+		{`mixed|@sum`, `0.501`},
+		{`empty|@sum`, `0`},
+		{`stock|@avg:{"path":"price"}`, `44.3266666666667`},
+		{`stock|@avg:{"path":"qty"}`, `2`},
+		{`empty|@avg`, `null`},
+		{`huge|@sum`, `2e+300`},
+		{`huge|@avg`, `1e+300`},
+		{`tiny|@sum`, `3e-09`},
+		{`tenths|@sum`, `0.6`},
+		{`tenths|@avg`, `0.2`},
+		{`stock|@min:{"path":"price"}`, `8.37`},
+		{`stock|@max:{"path":"price"}`, `95.85`},
+		{`big|@max`, `1e2`},
+		{`stock|@max:{"path":"qty","strict":true}`, ``},
+		{`stock|@count`, `3`},
+		{`stock|@count:{"path":"qty"}`, `2`},
+		{`nums|@count`, `5`},
+		{`stock.#(price>10)#|@sum:{"path":"price"}|@string`, `"124.61"`},
+		{`stock.0|@sum`, ``},
+	}
+	for _, tt := range tests {
+		if got := Get(json, tt.path).Unprocessed(); got != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSortTransformer(t *testing.T) {
+	json := `{"stock":[{"symbol":"MMM","price":95.85,"rank":2},{"symbol":"f","price":8.37,"rank":1},{"symbol":"GIS","price":28.76,"rank":2},{"symbol":"AMZN","price":8.37}],
+		"files":["file10","File2","file1"],"mixed":[true,"b",{"a":1},2,null,false,"a",1]}`
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`stock|@sort:{"by":"price"}|#.symbol`, `["f","AMZN","GIS","MMM"]`},
+		{`stock|@sort:{"by":"price","desc":true}|#.symbol`, `["MMM","GIS","f","AMZN"]`},
+		{`stock|@sort:{"by":"symbol"}|#.symbol`, `["AMZN","GIS","MMM","f"]`},
+		{`stock|@sort:{"by":"symbol","case_sensitive":false}|#.symbol`, `["AMZN","f","GIS","MMM"]`},
+		{`stock|@sort:{"by":["rank",{"path":"price","desc":true}]}|#.symbol`, `["AMZN","f","MMM","GIS"]`},
+		{`stock.#(price>=10)#|@sort:{"by":"symbol"}|#.symbol`, `["GIS","MMM"]`},
+		{`files|@sort`, `["File2","file1","file10"]`},
+		{`files|@sort:{"natural":true,"case_sensitive":false}`, `["file1","File2","file10"]`},
+		{`mixed|@sort`, `[null,false,1,2,"a","b",true,{"a":1}]`},
+		{`mixed|@sort:{"desc":true}`, `[{"a":1},true,"b","a",2,1,false,null]`},
+		{`stock.0|@sort`, ``},
+	}
+	for _, tt := range tests {
+		if got := Get(json, tt.path).Unprocessed(); got != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDistinctTransformers(t *testing.T) {
+	json := `{"values":[1,"a",1.0,{"x":1,"y":[2,3]},{"y":[2,3.0],"x":1},"a",null,1e0,"a",[1],null],
+		"bank":[{"name":"Ann","company":"HINWAY"},{"name":"Bob","company":"VERAQ"},{"name":"Cat","company":"HINWAY"},{"name":"Dan"},{"name":"Eve"}],
+		"nested":[0,-0,0.0,{"a":[1,{"b":2,"c":"\u00e9"}]},{"a":[1.0,{"c":"é","b":2e0}]},"0"]}`
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`values|@unique`, `[1,"a",{"x":1,"y":[2,3]},null,[1]]`},
+		{`values|@unique|#`, `5`},
+		{`bank|@distinctBy:{"path":"company"}|#.name`, `["Ann","Bob","Dan"]`},
+		{`bank.#.company|@unique`, `["HINWAY","VERAQ"]`},
+		{`values|@distinctBy`, `[1,"a",{"x":1,"y":[2,3]},null,[1]]`},
+		{`bank.#(company=="HINWAY")#|@distinctBy:{"path":"company"}|#.name`, `["Ann"]`},
+		{`nested|@unique`, `[0,{"a":[1,{"b":2,"c":"\u00e9"}]},"0"]`},
+		{`bank.0|@unique`, ``},
+	}
+	for _, tt := range tests {
+		if got := Get(json, tt.path).Unprocessed(); got != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGroupByTransformer(t *testing.T) {
+	json := `{"bank":[{"name":"Ann","gender":"female","age":30,"eyeColor":"blue"},{"name":"Bob","gender":"male","age":20,"eyeColor":"green"},
+		{"name":"Cat","gender":"female","age":21,"eyeColor":null},{"name":"Dan","gender":"male","age":40}],"nums":[1,"1",1.0,true,2],
+		"objs":[{"k":{"a":1,"b":[2]}},{"k":{"a":1 ,"b":[2] }},{"k":{"b":[2.0],"a":1e0}},{"k":[1,"x"]},{"k":null}]}`
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`bank|@groupBy:{"path":"gender"}|female.#.name`, `["Ann","Cat"]`},
+		{`bank|@groupBy:{"path":"gender"}|@keys`, `["female","male"]`},
+		{`bank|@groupBy:{"path":"gender","count":true}`, `{"female":2,"male":2}`},
+		{`bank|@groupBy:{"path":"eyeColor","count":true}`, `{"blue":1,"green":1,"null":2}`},
+		{`bank|@groupBy:{"path":"gender","aggregate":"#.age|@max"}`, `{"female":30,"male":40}`},
+		{`bank|@groupBy:{"path":"gender","aggregate":"#(age>25).name"}`, `{"female":"Ann","male":"Dan"}`},
+		{`bank|@groupBy:{"path":"gender","aggregate":"#(age>35).name"}`, `{"female":null,"male":"Dan"}`},
+		{`bank|@groupBy:{"path":"gender","count":true,"aggregate":"#.age|@max"}`, `{"female":2,"male":2}`},
+		{`bank.#(age<35)#|@groupBy:{"path":"gender"}|male.#.name`, `["Bob"]`},
+		{`nums|@groupBy`, `{"1":[1,"1",1.0],"true":[true],"2":[2]}`},
+		{`objs|@groupBy:{"path":"k","count":true}`, `{"{\"a\":1,\"b\":[2]}":3,"[1,\"x\"]":1,"null":1}`},
+		{`bank.0|@groupBy:{"path":"gender"}`, ``},
+	}
+	for _, tt := range tests {
+		if got := Get(json, tt.path).Unprocessed(); got != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
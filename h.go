@@ -1,10 +1,20 @@
 package fj
 
 import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"math"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 	"unicode/utf16"
 	"unicode/utf8"
@@ -141,6 +151,7 @@ func getNumeric(json string) (raw string, num float64) {
 // Parameters:
 //   - `json`: A byte slice containing the JSON data to process.
 //   - `path`: A string representing the path to extract data from the JSON.
+//   - `plan`: The pre-parsed segments of a compiled path (see `Compile`), or nil.
 //
 // Returns:
 //   - A `Context` struct containing processed and unprocessed strings representing
@@ -157,14 +168,14 @@ func getNumeric(json string) (raw string, num float64) {
 //
 //	jsonBytes := []byte(`{"key": "value", "nested": {"innerKey": "innerValue"}}`)
 //	path := "nested.innerKey"
-//	context := getBytes(jsonBytes, path)
+//	context := getBytes(jsonBytes, path, nil)
 //	fmt.Println("Unprocessed:", context.unprocessed) // Output: `{"key": "value", "nested": {"innerKey": "innerValue"}}`
 //	fmt.Println("Strings:", context.strings)         // Output: `{"innerKey": "innerValue"}`
-func getBytes(json []byte, path string) Context {
+func getBytes(json []byte, path string, plan *pathPlan) Context {
 	var result Context
 	if json != nil {
 		// unsafe cast json bytes to a string and process it using the Get function.
-		result = Get(*(*string)(unsafe.Pointer(&json)), path)
+		result = get(*(*string)(unsafe.Pointer(&json)), path, plan)
 		// extract the string headers for unprocessed and strings.
 		rawSafe := *(*stringHeader)(unsafe.Pointer(&result.unprocessed))
 		stringSafe := *(*stringHeader)(unsafe.Pointer(&result.strings))
@@ -888,7 +899,7 @@ func verifyNullable(data []byte, i int) (val int, ok bool) {
 //   - Each component is validated, and the function exits early with a false result if a part is invalid.
 func verifyNumeric(data []byte, i int) (val int, ok bool) {
 	// Check if i is within valid range
-	if i <= 0 || i >= len(data) {
+	if i <= 0 || i > len(data) {
 		return i, false
 	}
 	i--
@@ -1841,7 +1852,12 @@ func unescapeJSONEncoded(json string) (raw string, unescaped string) {
 //   - If the string starts with '@', it scans for a potential transformer by checking if there is a '.' or '|' after it,
 //     and verifies whether the transformer exists in the `transformers` map.
 //   - If the string starts with '[' or '{', it immediately returns `true`, as those characters typically indicate the start of a JSON array or object.
+//   - A deep wildcard component (`**`) also returns `true`, even when transformers are disabled, so that it is
+//     evaluated on the whole value it follows (see `deepWildcard`).
 func isTransformerOrJSONStart(s string) bool {
+	if isDeepWildcard(s) {
+		return true
+	}
 	if DisableTransformers {
 		return false
 	}
@@ -2504,7 +2520,12 @@ func parseJSONAny(json string, i int, hit bool) (int, Context, bool) {
 func parseJSONObject(c *parser, i int, path string) (int, bool) {
 	var _match, keyEsc, escVal, ok, hit bool
 	var key, val string
-	pathtransformers := parsePathWithTransformers(path)
+	var pathtransformers wildcard
+	if seg := c.plan.lookup(path); seg != nil {
+		pathtransformers = seg.wildcard
+	} else {
+		pathtransformers = parsePathWithTransformers(path)
+	}
 	if !pathtransformers.More && pathtransformers.Piped {
 		c.pipe = pathtransformers.Pipe
 		c.piped = true
@@ -2754,32 +2775,7 @@ func analyzeQuery(query string) (
 		path = trim(query[2:j])
 		value = trim(query[j:i])
 		remain = query[i+1:]
-		var trail int
-		switch {
-		case len(value) == 1:
-			trail = 1
-		case value[0] == '!' && value[1] == '=':
-			trail = 2
-		case value[0] == '!' && value[1] == '%':
-			trail = 2
-		case value[0] == '<' && value[1] == '=':
-			trail = 2
-		case value[0] == '>' && value[1] == '=':
-			trail = 2
-		case value[0] == '=' && value[1] == '=':
-			value = value[1:]
-			trail = 1
-		case value[0] == '<':
-			trail = 1
-		case value[0] == '>':
-			trail = 1
-		case value[0] == '=':
-			trail = 1
-		case value[0] == '%':
-			trail = 1
-		}
-		op = value[:trail]
-		value = trim(value[trail:])
+		op, value = splitQueryOperator(value)
 	} else {
 		path = trim(query[2:i])
 		remain = query[i+1:]
@@ -2787,6 +2783,99 @@ func analyzeQuery(query string) (
 	return path, op, value, remain, i + 1, _vEsc, true
 }
 
+// splitQueryOperator splits the comparison of a query, which starts at its operator, into the
+// operator and the trimmed value that follows it. The `==` operator is reported as `=`.
+// The `=~` operator is only a regular expression match when it is followed by a quoted
+// pattern, so that the tilde comparisons (e.g., `=~true`) keep their meaning.
+//
+// Parameters:
+//   - `value`: The comparison, starting with the operator (e.g., `>=10` or `=="Amazon.com"`).
+//
+// Returns:
+//   - `op`: The operator (e.g., `>=`), empty when the comparison does not start with one.
+//   - `rest`: The trimmed value after the operator.
+//
+// Example Usage:
+//
+//	op, rest := splitQueryOperator(`== "Amazon.com"`)
+//	// op: "="
+//	// rest: `"Amazon.com"`
+func splitQueryOperator(value string) (op, rest string) {
+	var trail int
+	switch {
+	case len(value) == 1:
+		trail = 1
+	case value[0] == '!' && value[1] == '=':
+		trail = 2
+	case value[0] == '!' && value[1] == '%':
+		trail = 2
+	case value[0] == '!' && value[1] == '~':
+		trail = 2
+	case value[0] == '<' && value[1] == '=':
+		trail = 2
+	case value[0] == '>' && value[1] == '=':
+		trail = 2
+	case value[0] == '=' && value[1] == '=':
+		value = value[1:]
+		trail = 1
+	case value[0] == '=' && value[1] == '~' && strings.HasPrefix(trim(value[2:]), `"`):
+		trail = 2
+	case value[0] == '<':
+		trail = 1
+	case value[0] == '>':
+		trail = 1
+	case value[0] == '=':
+		trail = 1
+	case value[0] == '%':
+		trail = 1
+	}
+	return value[:trail], trim(value[trail:])
+}
+
+// queryValuePath returns the path of the field referenced by the right-hand side of a query
+// comparison, such as `price_2002` for `@.price_2002`, or an empty string when the value is
+// not a relative reference. Quoted values are never references.
+//
+// Example Usage:
+//
+//	queryValuePath("@.price_2002")   // "price_2002"
+//	queryValuePath(`"@.price_2002"`) // ""
+func queryValuePath(value string) string {
+	if len(value) > 2 && value[0] == '@' && value[1] == '.' {
+		return value[2:]
+	}
+	return ""
+}
+
+// unquoteQueryValue removes the surrounding double quotes of a query value, and unescapes it
+// when it contains escape sequences. Values that are not quoted are returned unchanged.
+// The pattern of a regular expression operator (`=~`, `!~`) is kept as written, except for
+// escaped double quotes, so that escapes such as `\d` reach the regular expression.
+// The JSON literal of a membership operator (`in`, `!in`, `contains`) is kept as written.
+//
+// Parameters:
+//   - `op`: The operator of the query comparison.
+//   - `value`: The value of a query comparison (e.g., `"Amazon.com"` or `10`).
+//   - `esc`: Whether the value contains escape sequences.
+//
+// Returns:
+//   - The unquoted value.
+func unquoteQueryValue(op, value string, esc bool) string {
+	if isQueryLiteralOperator(op) {
+		return value
+	}
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+		if op == "=~" || op == "!~" {
+			return strings.ReplaceAll(value, `\"`, `"`)
+		}
+		if esc {
+			value = unescape(value)
+		}
+	}
+	return value
+}
+
 // analyzePath parses a string path into its structural components, breaking it into meaningful parts
 // such as the main path, pipe (if present), query parameters, and nested paths. This function is particularly
 // useful for processing JSON-like paths or other hierarchical data representations.
@@ -2897,16 +2986,19 @@ func analyzePath(path string) (r metadata) {
 					if !ok {
 						break
 					}
-					if len(value) >= 2 && value[0] == '"' &&
-						value[len(value)-1] == '"' {
-						value = value[1 : len(value)-1]
-						if escVal {
-							value = unescape(value)
+					if expr := path[2 : fi-1]; isLogicalQuery(expr) {
+						cond, ok := parseQueryConditions(expr)
+						if !ok {
+							break
 						}
+						r.query.Conditions = cond
+					} else if wPath, wOp, wValue, ok := splitQueryWordOperator(expr); ok {
+						queryPath, op, value = wPath, wOp, wValue
 					}
 					r.query.QueryPath = queryPath
 					r.query.Option = op
-					r.query.Value = value
+					r.query.Value = unquoteQueryValue(op, value, escVal)
+					r.query.ValuePath = queryValuePath(value)
 
 					i = fi - 1
 					if i+1 < len(path) && path[i+1] == '#' {
@@ -2974,20 +3066,30 @@ func analyzeArray(c *parser, i int, path string) (int, bool) {
 	var aLog []int
 	var partIdx int
 	var multics []byte
-	var queryIndexes []int
-	analysis := analyzePath(path)
-	if !analysis.Arch {
-		n, ok := parseUint64(analysis.Part)
-		if !ok {
-			partIdx = -1
-		} else {
-			partIdx = int(n)
+	queryIndexes := []int{}
+	var analysis metadata
+	if seg := c.plan.lookup(path); seg != nil {
+		analysis, partIdx = seg.analysis, seg.partIdx
+	} else {
+		analysis = analyzePath(path)
+		if !analysis.Arch {
+			n, ok := parseUint64(analysis.Part)
+			if !ok {
+				partIdx = -1
+			} else {
+				partIdx = int(n)
+			}
 		}
 	}
 	if !analysis.More && analysis.Piped {
 		c.pipe = analysis.Pipe
 		c.piped = true
 	}
+	if partIdx < 0 && !analysis.Arch && !c.lines {
+		if n, ok := parseNegativeIndex(analysis.Part); ok {
+			partIdx = countArrayElements(c.json, i) - n
+		}
+	}
 
 	executeQuery := func(eVal Context) bool {
 		if analysis.query.All {
@@ -3000,23 +3102,15 @@ func analyzeArray(c *parser, i int, path string) (int, bool) {
 		computeIndex(c.json, &tmp)
 		parentIndex := tmp.value.index
 		var res Context
-		if eVal.kind == JSON {
-			res = eVal.Get(analysis.query.QueryPath)
-		} else {
-			if analysis.query.QueryPath != "" {
-				return false
-			}
-			res = eVal
-		}
-		if matchesQueryConditions(&analysis, res) {
+		if matchesQuery(&analysis, eVal, c.plan) {
 			if analysis.More {
-				left, right, ok := splitPathPipe(analysis.Path)
+				left, right, ok := c.plan.splitPathPipe(analysis.Path)
 				if ok {
 					analysis.Path = left
 					c.pipe = right
 					c.piped = true
 				}
-				res = eVal.Get(analysis.Path)
+				res = eVal.get(analysis.Path, c.plan)
 			} else {
 				res = eVal
 			}
@@ -3180,7 +3274,7 @@ func analyzeArray(c *parser, i int, path string) (int, bool) {
 			case ']':
 				if analysis.Arch && analysis.Part == "#" {
 					if analysis.ALogOk {
-						left, right, ok := splitPathPipe(analysis.ALogKey)
+						left, right, ok := c.plan.splitPathPipe(analysis.ALogKey)
 						if ok {
 							analysis.ALogKey = left
 							c.pipe = right
@@ -3202,7 +3296,7 @@ func analyzeArray(c *parser, i int, path string) (int, bool) {
 							if idx < len(c.json) && c.json[idx] != ']' {
 								_, res, ok := parseJSONAny(c.json, idx, true)
 								if ok {
-									res := res.Get(analysis.ALogKey)
+									res := res.get(analysis.ALogKey, c.plan)
 									if res.Exists() {
 										if k > 0 {
 											jsonVal = append(jsonVal, ',')
@@ -3245,6 +3339,7 @@ func analyzeArray(c *parser, i int, path string) (int, bool) {
 						c.value = Context{
 							unprocessed: "[]",
 							kind:        JSON,
+							indexes:     queryIndexes,
 						}
 					}
 				}
@@ -3276,6 +3371,382 @@ func analyzeArray(c *parser, i int, path string) (int, bool) {
 	return i, false
 }
 
+// parseNegativeIndex parses a negative array index path component, such as `-1` for the last
+// element of an array.
+//
+// Parameters:
+//   - `part`: A path component.
+//
+// Returns:
+//   - The distance of the element from the end of the array (e.g., 1 for `-1`).
+//   - A boolean indicating whether the component is a negative index.
+func parseNegativeIndex(part string) (int, bool) {
+	if len(part) < 2 || part[0] != '-' {
+		return 0, false
+	}
+	n, ok := parseUint64(part[1:])
+	if !ok || n == 0 || n > math.MaxInt32 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// countArrayElements returns the number of elements of the array whose first element starts at
+// or after index `i` of the JSON string, that is, right after the opening bracket.
+//
+// Parameters:
+//   - `json`: The JSON string.
+//   - `i`: The index following the opening bracket of the array.
+//
+// Returns:
+//   - The number of elements of the array.
+func countArrayElements(json string, i int) int {
+	if i < 1 || i > len(json) {
+		return 0
+	}
+	_, raw := parseJSONSquash(json, i-1)
+	n := 0
+	Context{kind: JSON, unprocessed: raw}.Foreach(func(_, _ Context) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// parseSliceSelector parses an array slice path component, such as `[2:5]`, `[::2]` or `[-3:]`,
+// which selects the elements from a start index up to, but excluding, an end index, taking
+// every `step` element. Negative indexes count from the end of the array, and omitted bounds
+// default to the whole array, following the Python slice semantics.
+//
+// Parameters:
+//   - `path`: The path, starting with the slice component.
+//
+// Returns:
+//   - `bounds`: The start, end and step of the slice, nil when omitted.
+//   - `rest`: The remainder of the path after the slice component, starting with '.' or '|', if any.
+//   - `ok`: A boolean indicating whether the path starts with a slice component.
+//
+// Example Usage:
+//
+//	bounds, rest, ok := parseSliceSelector("[-3:]|#.symbol")
+//	// *bounds[0]: -3, bounds[1]: nil, bounds[2]: nil
+//	// rest: "|#.symbol"
+//	// ok: true
+func parseSliceSelector(path string) (bounds [3]*int, rest string, ok bool) {
+	if len(path) < 3 || path[0] != '[' {
+		return bounds, "", false
+	}
+	end := strings.IndexByte(path, ']')
+	if end < 0 || end+1 < len(path) && path[end+1] != '.' && path[end+1] != '|' {
+		return bounds, "", false
+	}
+	parts := strings.Split(path[1:end], ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return bounds, "", false
+	}
+	for k, part := range parts {
+		part = trim(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || part[0] == '+' {
+			return bounds, "", false
+		}
+		bounds[k] = &n
+	}
+	if bounds[2] != nil && *bounds[2] == 0 {
+		return bounds, "", false
+	}
+	return bounds, path[end+1:], true
+}
+
+// sliceContext selects the elements of an array with a slice (see `parseSliceSelector`), and
+// evaluates the rest of the path on the resulting array.
+//
+// The elements keep their locations in the original JSON, so that `Context.Paths` can be used on
+// the result: they are taken from the `Indexes` of the array when it is the result of a query, or
+// computed from its `Index` otherwise.
+//
+// Parameters:
+//   - `ctx`: The array to slice.
+//   - `bounds`: The start, end and step of the slice.
+//   - `rest`: The remainder of the path, starting with '.' or '|', or empty.
+//   - `plan`: The compiled path being evaluated, or nil.
+//
+// Returns:
+//   - A `Context` holding the array of the selected elements, or the result of the rest of the
+//     path on that array. The result does not exist if `ctx` is not an array.
+func sliceContext(ctx Context, bounds [3]*int, rest string, plan *pathPlan) Context {
+	if !ctx.IsArray() {
+		return Context{}
+	}
+	var elems []Context
+	ctx.Foreach(func(_, value Context) bool {
+		elems = append(elems, value)
+		return true
+	})
+	picks := sliceIndexes(len(elems), bounds)
+	values := make([]Context, len(picks))
+	for k, pick := range picks {
+		values[k] = elems[pick]
+	}
+	return collectContexts(values, rest, plan)
+}
+
+// isDeepWildcard reports whether a path starts with the deep wildcard component `**`, which
+// descends into a value at any depth.
+//
+// Example Usage:
+//
+//	isDeepWildcard("**.email")  // true
+//	isDeepWildcard("**")        // true
+//	isDeepWildcard("**ailments") // false (a key wildcard)
+func isDeepWildcard(path string) bool {
+	return len(path) >= 2 && path[0] == '*' && path[1] == '*' &&
+		(len(path) == 2 || path[2] == '.' || path[2] == '|')
+}
+
+// deepWildcard evaluates a path that starts with the deep wildcard component `**` on a value.
+//
+// With `**.path`, the path is matched against the value and every object and array nested in it,
+// at any depth, and all the matches are returned as an array in document order. The value is
+// walked once, and the first component of the path is matched against the members and elements
+// of each nested object and array during the walk:
+//   - A key (possibly escaped, or with `*` and `?` wildcards) or an array index selects the
+//     matching members and elements.
+//   - `#` selects the length of each array, and `#.path` the array of the results of the path on
+//     its elements.
+//   - A query such as `#(age>30)` selects the first matching element of each array, and a query
+//     such as `#(age>30)#` the array of its matching elements.
+//
+// The rest of the path is then evaluated on the selected values. Only the components that have no
+// meaning per nested value, such as transformers, literals and multi-selectors, fall back to the
+// evaluation of the whole path on every nested object and array. The `**` component on its own
+// returns every nested value, and repeated deep wildcards (`**.**`) are the same as one.
+//
+// A pipe after the path (e.g., `**.email|@reverse`, or `**|#`) applies to the array of matches.
+//
+// Parameters:
+//   - `ctx`: The value to search, with its `Index` in the original JSON.
+//   - `rest`: The remainder of the path after `**`, starting with '.' or '|', or empty.
+//   - `plan`: The compiled path being evaluated, or nil.
+//
+// Returns:
+//   - A `Context` holding the array of matches, with the location of each match in the original
+//     JSON so that `Context.Paths` can be used on it. The matches computed rather than found in the
+//     JSON, such as the lengths of `**.#`, are located by the path that computes them (e.g. "a.#").
+//
+// Example Usage:
+//
+//	json := `{"a":{"email":"x"},"b":[{"email":"y"}],"email":"z"}`
+//	deepWildcard(Parse(json), ".email", nil).String() // ["x","y","z"]
+func deepWildcard(ctx Context, rest string, plan *pathPlan) Context {
+	var path, pipe string
+	if len(rest) > 0 && rest[0] == '.' {
+		path = rest[1:]
+		if left, right, ok := plan.splitPathPipe(path); ok {
+			path, pipe = left, "|"+right
+		}
+	} else {
+		pipe = rest
+	}
+	// `**.**` matches the same values as `**`, so repeated deep wildcards are collapsed rather than
+	// returning every value once per enclosing object or array.
+	for isDeepWildcard(path) {
+		path = strings.TrimPrefix(path[2:], ".")
+	}
+	key, remain, simple := path, "", true
+	var unescaped []byte
+	wild, escaped := false, false
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+1 < len(path) {
+			i++
+			escaped = true
+			unescaped = append(unescaped, path[i])
+			continue
+		}
+		if path[i] == '#' || path[i] == '@' || path[i] == '!' ||
+			path[i] == '[' || path[i] == '{' || path[i] == '(' {
+			simple = false
+			break
+		}
+		if path[i] == '.' {
+			key, remain = path[:i], path[i+1:]
+			break
+		}
+		wild = wild || path[i] == '*' || path[i] == '?'
+		unescaped = append(unescaped, path[i])
+	}
+	if key == "" || isDeepWildcard(key) {
+		simple = false
+	}
+	if escaped && !wild {
+		key = string(unescaped)
+	}
+	// the `#` components are analyzed once, and matched against the elements of each array.
+	var analysis metadata
+	arch := !simple && len(path) > 0 && path[0] == '#'
+	if arch {
+		analysis = analyzePath(path)
+		// a malformed query has neither a path nor an operator, and is left to the evaluation of
+		// the whole path, which does not match it.
+		malformed := analysis.query.On && analysis.query.Conditions == nil &&
+			analysis.query.QueryPath == "" && analysis.query.Option == ""
+		arch = !analysis.Piped && !malformed
+	}
+	// the values computed rather than found in the JSON, such as the length of an array, are
+	// located by the path that computes them from a value found in the JSON (see `Context.Paths`),
+	// recorded in `origins`.
+	var values []Context
+	var origins []valueOrigin
+	add := func(value Context, index int, path string) {
+		values = append(values, value)
+		origins = append(origins, valueOrigin{index: index, path: path})
+	}
+	var walk func(node Context)
+	walk = func(node Context) {
+		if !simple && !arch && path != "" {
+			// queries that return all their matches, such as `#(...)#`, yield an empty array on
+			// the arrays without matches, which is not a match.
+			if res := node.get(path, plan); res.Exists() && (res.indexes == nil || len(res.indexes) > 0) {
+				add(res, node.index, path)
+			}
+		}
+		// the result of a `#` component on an array precedes the results nested in its elements.
+		slot := -1
+		if arch && node.IsArray() {
+			slot = len(values)
+			add(Context{}, node.index, path)
+		}
+		var count int
+		var matches []Context
+		var matched bool
+		node.Foreach(func(k, value Context) bool {
+			switch {
+			case path == "":
+				add(value, 0, "")
+			case simple:
+				var match bool
+				if k.kind == String {
+					match = !wild && k.strings == key || wild && matchSafely(k.strings, key)
+				} else {
+					match = strconv.Itoa(int(k.numeric)) == key
+				}
+				if match {
+					if remain == "" {
+						add(value, 0, "")
+					} else if res := value.get(remain, plan); res.Exists() {
+						add(res, value.index, remain)
+					}
+				}
+			case arch && k.kind != String:
+				count++
+				if analysis.query.On {
+					if matched || !matchesQuery(&analysis, value, plan) {
+						break
+					}
+					res := value
+					if analysis.More {
+						res = value.get(analysis.Path, plan)
+					}
+					if !analysis.query.All {
+						matched = true
+						values[slot] = res
+					} else if res.Exists() {
+						matches = append(matches, res)
+					}
+				} else if analysis.ALogOk {
+					if res := value.get(analysis.ALogKey, plan); res.Exists() {
+						matches = append(matches, res)
+					}
+				}
+			}
+			if value.kind == JSON {
+				walk(value)
+			}
+			return true
+		})
+		if slot >= 0 {
+			switch {
+			case len(matches) > 0:
+				values[slot] = collectContexts(matches, "", plan)
+			case !analysis.query.On && !analysis.ALogOk:
+				values[slot] = Context{kind: Number, numeric: float64(count), unprocessed: strconv.Itoa(count)}
+			}
+			if !values[slot].Exists() {
+				values = append(values[:slot], values[slot+1:]...)
+				origins = append(origins[:slot], origins[slot+1:]...)
+			}
+		}
+	}
+	if ctx.kind == JSON {
+		walk(ctx)
+	}
+	res := collectContexts(values, pipe, plan)
+	if pipe == "" {
+		res.origins = origins
+	}
+	return res
+}
+
+// collectContexts builds the array of a list of values, and evaluates the rest of the path on it.
+//
+// The elements of the array keep their locations in the original JSON, so that `Context.Paths`
+// can be used on the result. When the rest of the path is evaluated, the locations of its results
+// are translated back to the original JSON through the element that contains them.
+//
+// Parameters:
+//   - `values`: The elements of the array, with their `Index` in the original JSON.
+//   - `rest`: The remainder of the path, starting with '.' or '|', or empty.
+//   - `plan`: The compiled path being evaluated, or nil.
+//
+// Returns:
+//   - A `Context` holding the array, or the result of the rest of the path on that array.
+func collectContexts(values []Context, rest string, plan *pathPlan) Context {
+	b := make([]byte, 0, 64)
+	b = append(b, '[')
+	indexes := make([]int, 0, len(values))
+	offsets := make([]int, 0, len(values))
+	for k, value := range values {
+		if k > 0 {
+			b = append(b, ',')
+		}
+		raw := value.unprocessed
+		if len(raw) == 0 {
+			raw = value.String()
+		}
+		offsets = append(offsets, len(b))
+		b = append(b, raw...)
+		indexes = append(indexes, value.index)
+	}
+	b = append(b, ']')
+	res := Context{kind: JSON, unprocessed: string(b), indexes: indexes}
+	if len(rest) == 0 {
+		return res
+	}
+	locate := func(i int) int {
+		for k := len(offsets) - 1; k >= 0; k-- {
+			if i >= offsets[k] {
+				if i-offsets[k] < len(values[k].unprocessed) {
+					return indexes[k] + i - offsets[k]
+				}
+				break
+			}
+		}
+		return 0
+	}
+	res = res.get(rest[1:], plan)
+	if res.indexes != nil {
+		for k, i := range res.indexes {
+			res.indexes[k] = locate(i)
+		}
+	} else {
+		res.index = locate(res.index)
+	}
+	return res
+}
+
 // analyzeSubSelectors parses a sub-selection string, which can either be in the form of
 // '[path1,path2]' or '{"field1":path1,"field2":path2}' type structure. It returns the parsed
 // selectors from the given path, which includes the name and path of each selector within
@@ -3418,6 +3889,36 @@ func analyzeSubSelectors(path string) (selectors []subSelector, out string, ok b
 //     string and returns the result along with the remaining path. If no valid transformer is found, it
 //     returns the original path and an empty result.
 func adjustTransformer(json, path string) (pathYield, result string, ok bool) {
+	fn, args, pathYield, ok := parseTransformer(path)
+	if !ok {
+		// if no transformer is found, return the path and an empty result.
+		return pathYield, result, false
+	}
+	// apply the transformer function to the JSON data and return the result.
+	return pathYield, fn(json, args), true
+}
+
+// parseTransformer parses a path that starts with a '@' to identify a registered transformer function and
+// its arguments, without applying it. This is the parsing half of `adjustTransformer`, which allows a
+// compiled path to resolve its transformers once and apply them many times.
+//
+// Parameters:
+//   - path: A string representing the path, which includes a transformer prefixed by '@'.
+//
+// Returns:
+//   - fn: The registered transformer function, or nil if no transformer is found.
+//   - args: The raw argument of the transformer (the text following ':'), or an empty string.
+//   - pathYield: The remaining portion of the path after the transformer and its arguments.
+//   - ok: A boolean indicating whether the transformer is registered.
+//
+// Example Usage:
+//
+//	fn, args, pYield, ok := parseTransformer(`@pretty:{"indent":"\t"}|name`)
+//	// fn: transformPretty
+//	// args: {"indent":"\t"}
+//	// pYield: |name
+//	// ok: true
+func parseTransformer(path string) (fn func(json, arg string) string, args, pathYield string, ok bool) {
 	name := path[1:] // remove the '@' character and initialize the name to the remaining path.
 	var hasArgs bool
 	// iterate over the path to find the transformer name and any arguments.
@@ -3441,43 +3942,40 @@ func adjustTransformer(json, path string) (pathYield, result string, ok bool) {
 			break
 		}
 	}
-	// check if the transformer exists in the transformers map and apply it if found.
-	if fn, ok := jsonTransformers[name]; ok {
-		var args string
-		if hasArgs { // if arguments are found, parse and handle them.
-			var parsedArgs bool
-			// process the arguments based on their type (e.g., JSON, string, etc.).
-			switch pathYield[0] {
-			case '{', '[', '"': // handle JSON-like arguments.
-				ctx := Parse(pathYield)
-				if ctx.Exists() {
-					args = squash(pathYield) // squash the JSON to remove nested structures.
-					pathYield = pathYield[len(args):]
-					parsedArgs = true
-				}
+	// check if the transformer exists in the transformers map.
+	if fn, ok = jsonTransformers[name]; !ok {
+		return nil, "", pathYield, false
+	}
+	if hasArgs { // if arguments are found, parse and handle them.
+		var parsedArgs bool
+		// process the arguments based on their type (e.g., JSON, string, etc.).
+		switch pathYield[0] {
+		case '{', '[', '"': // handle JSON-like arguments.
+			ctx := Parse(pathYield)
+			if ctx.Exists() {
+				args = squash(pathYield) // squash the JSON to remove nested structures.
+				pathYield = pathYield[len(args):]
+				parsedArgs = true
 			}
-			if !parsedArgs { // process arguments if not already parsed as JSON.
-				i := 0
-				// iterate through the arguments and process any nested structures or strings.
-				for ; i < len(pathYield); i++ {
-					if pathYield[i] == '|' {
-						break
-					}
-					switch pathYield[i] {
-					case '{', '[', '"', '(': // handle nested structures like arrays or objects.
-						s := squash(pathYield[i:])
-						i += len(s) - 1
-					}
+		}
+		if !parsedArgs { // process arguments if not already parsed as JSON.
+			i := 0
+			// iterate through the arguments and process any nested structures or strings.
+			for ; i < len(pathYield); i++ {
+				if pathYield[i] == '|' {
+					break
+				}
+				switch pathYield[i] {
+				case '{', '[', '"', '(': // handle nested structures like arrays or objects.
+					s := squash(pathYield[i:])
+					i += len(s) - 1
 				}
-				args = pathYield[:i]      // extract the argument portion.
-				pathYield = pathYield[i:] // update the remaining path.
 			}
+			args = pathYield[:i]      // extract the argument portion.
+			pathYield = pathYield[i:] // update the remaining path.
 		}
-		// apply the transformer function to the JSON data and return the result.
-		return pathYield, fn(json, args), true
 	}
-	// if no transformer is found, return the path and an empty result.
-	return pathYield, result, false
+	return fn, args, pathYield, true
 }
 
 // isNullish checks whether a given `Context` represents a JSON null value.
@@ -3633,6 +4131,8 @@ func isTruthy(t Context) bool {
 //   - `>`, `>=`: Checks if the value is greater than or equal to the query value.
 //   - `%`: Checks if the value matches a regular expression (string only).
 //   - `!%`: Checks if the value does not match a regular expression (string only).
+//   - `=~`: Checks if the value matches a Go RE2 regular expression (string only).
+//   - `!~`: Checks if the value does not match a Go RE2 regular expression (string only).
 //
 // Example Usage:
 //
@@ -3650,7 +4150,20 @@ func isTruthy(t Context) bool {
 //   - String pattern matching (`%`, `!%`) relies on the `matchSafely` function, which is not defined here.
 //   - Unsupported types or operations return `false`.
 func matchesQueryConditions(dp *metadata, value Context) bool {
-	mt := dp.query.Value
+	return matchesCondition(dp.query.Option, dp.query.Value, value)
+}
+
+// matchesCondition reports whether a `Context` value satisfies a single query comparison.
+// It implements the matching rules documented on `matchesQueryConditions`.
+//
+// Parameters:
+//   - `option`: The comparison operator (e.g., `=`, `!=`, `<`), empty for an existence check.
+//   - `mt`: The unquoted value the `Context` is compared with.
+//   - `value`: The `Context` to evaluate.
+//
+// Returns:
+//   - `true` if the `Context` satisfies the comparison, otherwise `false`.
+func matchesCondition(option, mt string, value Context) bool {
 	if len(mt) > 0 {
 		if mt[0] == '~' {
 			mt = mt[1:]
@@ -3678,15 +4191,51 @@ func matchesQueryConditions(dp *metadata, value Context) bool {
 			}
 		}
 	}
+	return compareCondition(option, mt, value)
+}
+
+// compareCondition applies a query comparison operator to a `Context` value and the value it is
+// compared with, following the type of the `Context` (see `matchesQueryConditions`).
+//
+// Parameters:
+//   - `option`: The comparison operator, empty for an existence check.
+//   - `mt`: The value the `Context` is compared with.
+//   - `value`: The `Context` to evaluate.
+//
+// Returns:
+//   - `true` if the comparison holds, otherwise `false`.
+func compareCondition(option, mt string, value Context) bool {
 	if !value.Exists() {
 		return false
 	}
-	if dp.query.Option == "" {
+	switch option {
+	case "":
 		return true
+	case "in", "!in":
+		found := false
+		parseQueryLiteral(mt).Foreach(func(_, elem Context) bool {
+			found = jsonEqual(elem, value)
+			return !found
+		})
+		return found == (option == "in")
+	case "contains":
+		found := false
+		if value.IsArray() {
+			literal := parseQueryLiteral(mt)
+			value.Foreach(func(_, elem Context) bool {
+				found = jsonEqual(elem, literal)
+				return !found
+			})
+		}
+		return found
+	case "=", "!=":
+		if value.kind == JSON || len(mt) > 0 && (mt[0] == '[' || mt[0] == '{') {
+			return jsonEqual(value, parseQueryLiteral(mt)) == (option == "=")
+		}
 	}
 	switch value.kind {
 	case String:
-		switch dp.query.Option {
+		switch option {
 		case "=":
 			return value.strings == mt
 		case "!=":
@@ -3703,10 +4252,14 @@ func matchesQueryConditions(dp *metadata, value Context) bool {
 			return matchSafely(value.strings, mt)
 		case "!%":
 			return !matchSafely(value.strings, mt)
+		case "=~":
+			return matchesQueryRegexp(value.strings, mt)
+		case "!~":
+			return !matchesQueryRegexp(value.strings, mt)
 		}
 	case Number:
 		_rightVal, _ := strconv.ParseFloat(mt, 64)
-		switch dp.query.Option {
+		switch option {
 		case "=":
 			return value.numeric == _rightVal
 		case "!=":
@@ -3721,7 +4274,7 @@ func matchesQueryConditions(dp *metadata, value Context) bool {
 			return value.numeric >= _rightVal
 		}
 	case True:
-		switch dp.query.Option {
+		switch option {
 		case "=":
 			return mt == "true"
 		case "!=":
@@ -3732,7 +4285,7 @@ func matchesQueryConditions(dp *metadata, value Context) bool {
 			return true
 		}
 	case False:
-		switch dp.query.Option {
+		switch option {
 		case "=":
 			return mt == "false"
 		case "!=":
@@ -3746,61 +4299,500 @@ func matchesQueryConditions(dp *metadata, value Context) bool {
 	return false
 }
 
-// appendJSON converts a given string into a valid JSON string format
-// and appends it to the provided byte slice `dst`.
-//
-// This function escapes special characters in the input string `s` to ensure
-// that it adheres to the JSON string encoding rules, such as escaping double
-// quotes, backslashes, and control characters. Additionally, it handles UTF-8
-// characters and appends them in their proper encoded format.
+// compileQueryRegexp compiles the regular expression of a `=~` or `!~` query operator, caching
+// the result in `queryRegexpCache` so that a pattern is only compiled once.
 //
 // Parameters:
-//   - dst: A byte slice to which the encoded JSON string will be appended.
-//   - s: The input string to be converted into JSON string format.
+//   - `pattern`: A Go RE2 regular expression.
 //
 // Returns:
-//   - []byte: The resulting byte slice containing the original content of `dst`
-//     with the JSON-encoded string appended.
+//   - The compiled regular expression, or an error if the pattern is invalid.
 //
-// Details:
-//   - The function begins by appending space for the string `s` and wrapping
-//     it in double quotes.
-//   - It iterates through the input string `s` character by character and checks
-//     for specific cases where escaping or additional encoding is required:
-//   - Control characters (`\n`, `\r`, `\t`) are replaced with their escape
-//     sequences (`\\n`, `\\r`, `\\t`).
-//   - Characters like `<`, `>`, and `&` are escaped using Unicode notation
-//     to ensure the resulting JSON string is safe for embedding in HTML or XML.
-//   - Backslashes (`\`) and double quotes (`"`) are escaped with a preceding
-//     backslash (`\\`).
-//   - UTF-8 characters are properly encoded, and unsupported characters or
-//     decoding errors are replaced with the Unicode replacement character
-//     (`\ufffd`).
+// Notes:
+//   - Invalid patterns are never cached, and once the cache holds `maxQueryRegexps` patterns, new
+//     patterns are compiled on every call.
+func compileQueryRegexp(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := queryRegexpCache.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if queryRegexpCount.Load() < maxQueryRegexps {
+		if _, loaded := queryRegexpCache.LoadOrStore(pattern, re); !loaded {
+			queryRegexpCount.Add(1)
+		}
+	}
+	return re, nil
+}
+
+// matchesQueryRegexp reports whether a string matches the regular expression of a `=~` or `!~`
+// query operator. An invalid pattern never matches.
 //
 // Example Usage:
 //
-//	dst := []byte("Current JSON: ")
-//	s := "Hello \"world\"\nLine break!"
-//	result := appendJSON(dst, s)
-//	// result: []byte(`Current JSON: "Hello \"world\"\nLine break!"`)
+//	matchesQueryRegexp("+1 (855) 461-3545", `^\+1 \(8\d\d\)`) // true
+func matchesQueryRegexp(s, pattern string) bool {
+	re, err := compileQueryRegexp(pattern)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(s)
+}
+
+// splitQueryWordOperator splits a query comparison that uses one of the word operators `in`,
+// `!in` or `contains` into its path, operator and value. A word operator must follow a path and
+// whitespace, or start the comparison to compare the element itself (e.g., `#(in [1,2])`), and
+// must be followed by whitespace and a value, so that keys named after an operator (e.g.,
+// `#(in=="x")` or `#(contains > 4)`) are still compared as paths. Word operators that appear
+// after a comparison operator, inside strings or inside nested brackets are ignored.
 //
-// Notes:
-//   - This function is useful for building JSON-encoded strings dynamically
-//     without allocating new memory for each operation.
-//   - It ensures that the resulting JSON string is safe and adheres to
-//     encoding rules for use in various contexts such as web APIs or
-//     configuration files.
-func appendJSON(target []byte, s string) []byte {
-	target = append(target, make([]byte, len(s)+2)...)
-	target = append(target[:len(target)-len(s)-2], '"')
-	for i := 0; i < len(s); i++ {
-		if s[i] < ' ' {
-			target = append(target, '\\')
-			switch s[i] {
-			case '\n':
-				target = append(target, 'n')
-			case '\r':
-				target = append(target, 'r')
+// Parameters:
+//   - `expr`: The comparison (e.g., `status in ["active","pending"]`).
+//
+// Returns:
+//   - `path`: The trimmed path before the operator (e.g., `status`).
+//   - `op`: The operator (e.g., `in`).
+//   - `value`: The trimmed value after the operator (e.g., `["active","pending"]`).
+//   - `ok`: `true` if the comparison uses a word operator.
+//
+// Example Usage:
+//
+//	path, op, value, ok := splitQueryWordOperator(`tags contains "a"`)
+//	// path: "tags", op: "contains", value: `"a"`, ok: true
+//
+//	path, op, value, ok = splitQueryWordOperator(`in [1,2]`)
+//	// path: "", op: "in", value: `[1,2]`, ok: true
+func splitQueryWordOperator(expr string) (path, op, value string, ok bool) {
+	// a word operator that starts the comparison is only used when no later one follows a path,
+	// so that `contains in [1,2]` still tests the "contains" key.
+	var leadOp, leadValue string
+	depth := 0
+	for i := 0; i < len(expr); i++ {
+		if depth == 0 && (i == 0 || expr[i-1] <= ' ') {
+			path = strings.TrimSpace(expr[:i])
+			for _, word := range []string{"!in", "in", "contains"} {
+				end := i + len(word)
+				if !strings.HasPrefix(expr[i:], word) || end >= len(expr) || expr[end] > ' ' {
+					continue
+				}
+				if value = trim(expr[end:]); value == "" {
+					continue
+				}
+				if path != "" {
+					return path, word, value, true
+				}
+				if leadOp == "" && !strings.ContainsRune("=!<>%", rune(value[0])) {
+					leadOp, leadValue = word, value
+				}
+			}
+		}
+		switch expr[i] {
+		case '\\':
+			i++
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		case '"':
+			i = skipQueryString(expr, i)
+		case '!', '=', '<', '>', '%':
+			if depth == 0 && leadOp == "" {
+				return "", "", "", false
+			}
+		}
+	}
+	if leadOp != "" {
+		return "", leadOp, leadValue, true
+	}
+	return "", "", "", false
+}
+
+// isQueryLiteralOperator reports whether a query operator takes a JSON literal as its value:
+// the membership operators `in` and `!in`, and the `contains` operator.
+func isQueryLiteralOperator(op string) bool {
+	return op == "in" || op == "!in" || op == "contains"
+}
+
+// parseQueryLiteral parses the literal value of a query comparison as JSON. A value that is not
+// valid JSON, such as an unquoted word, is treated as a string.
+//
+// Example Usage:
+//
+//	parseQueryLiteral(`["a","b"]`) // an array
+//	parseQueryLiteral(`active`)    // the string "active"
+func parseQueryLiteral(raw string) Context {
+	if _, ok := verifyJSON([]byte(raw), 0); ok {
+		return Parse(raw)
+	}
+	return Context{kind: String, strings: raw}
+}
+
+// isLogicalQuery reports whether the expression of a query combines comparisons with the
+// logical operators `&&`, `||` and `!`, or groups them with parentheses. Operators that
+// appear inside strings or nested brackets are ignored.
+//
+// Parameters:
+//   - `expr`: The expression between the brackets of a `#(...)` query.
+//
+// Returns:
+//   - `true` if the expression must be parsed with `parseQueryConditions`, otherwise `false`.
+//
+// Example Usage:
+//
+//	isLogicalQuery(`gender=="female" && age<30`) // true
+//	isLogicalQuery(`!(isActive==true)`)         // true
+//	isLogicalQuery(`name!="Stark"`)             // false
+func isLogicalQuery(expr string) bool {
+	expr = trim(expr)
+	if len(expr) > 0 && (expr[0] == '(' || expr[0] == '!' && !isQueryNegatedOperator(expr)) {
+		return true
+	}
+	depth := 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			i++
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		case '"':
+			i = skipQueryString(expr, i)
+		case '&', '|':
+			if depth == 0 && i+1 < len(expr) && expr[i+1] == expr[i] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isQueryNegatedOperator reports whether an expression starting with `!` starts with a
+// comparison operator such as `!=`, `!%`, `!~` or the `!in` word operator of an element
+// (`!in [1,2]`), rather than with the logical `!` operator.
+func isQueryNegatedOperator(expr string) bool {
+	if len(expr) > 4 && strings.HasPrefix(expr, "!in") && expr[3] <= ' ' {
+		_, _, _, ok := splitQueryWordOperator(expr)
+		return ok
+	}
+	return len(expr) > 1 && (expr[1] == '=' || expr[1] == '%' || expr[1] == '~')
+}
+
+// skipQueryString returns the index of the closing double quote of the string that starts at
+// index `i` of a query expression, or the length of the expression when it is not closed.
+func skipQueryString(expr string, i int) int {
+	for i++; i < len(expr); i++ {
+		if expr[i] == '\\' {
+			i++
+		} else if expr[i] == '"' {
+			break
+		}
+	}
+	return i
+}
+
+// parseQueryConditions parses the expression of a query that combines comparisons with the
+// logical operators `&&`, `||` and `!`, and parentheses, into a `queryCondition` tree.
+//
+// The grammar follows the usual precedence, from lowest to highest:
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" or ")" | comparison
+//	comparison = path [ operator value ]
+//
+// Parameters:
+//   - `expr`: The expression between the brackets of a `#(...)` query.
+//
+// Returns:
+//   - The root of the expression tree.
+//   - `false` if the expression is malformed (e.g., unbalanced parentheses or an empty operand).
+//
+// Example Usage:
+//
+//	cond, ok := parseQueryConditions(`gender=="female" && (age<30 || isActive==true)`)
+//	// cond.op: "&&"
+//	// cond.left.path: "gender"
+//	// cond.right.op: "||"
+//	// ok: true
+func parseQueryConditions(expr string) (*queryCondition, bool) {
+	pos := 0
+	skipSpace := func() {
+		for pos < len(expr) && expr[pos] <= ' ' {
+			pos++
+		}
+	}
+	consume := func(op string) bool {
+		skipSpace()
+		if strings.HasPrefix(expr[pos:], op) {
+			pos += len(op)
+			return true
+		}
+		return false
+	}
+	var parseOr, parseAnd, parseUnary func() (*queryCondition, bool)
+	parseOr = func() (*queryCondition, bool) {
+		left, ok := parseAnd()
+		for ok && consume("||") {
+			var right *queryCondition
+			right, ok = parseAnd()
+			left = &queryCondition{op: "||", left: left, right: right}
+		}
+		return left, ok
+	}
+	parseAnd = func() (*queryCondition, bool) {
+		left, ok := parseUnary()
+		for ok && consume("&&") {
+			var right *queryCondition
+			right, ok = parseUnary()
+			left = &queryCondition{op: "&&", left: left, right: right}
+		}
+		return left, ok
+	}
+	parseUnary = func() (*queryCondition, bool) {
+		skipSpace()
+		if pos < len(expr) && expr[pos] == '!' && !isQueryNegatedOperator(expr[pos:]) {
+			pos++
+			operand, ok := parseUnary()
+			return &queryCondition{op: "!", left: operand}, ok
+		}
+		if pos < len(expr) && expr[pos] == '(' {
+			pos++
+			cond, ok := parseOr()
+			if !ok || !consume(")") {
+				return nil, false
+			}
+			return cond, true
+		}
+		end := scanQueryOperand(expr, pos)
+		operand := trim(expr[pos:end])
+		pos = end
+		if operand == "" {
+			return nil, false
+		}
+		return parseQueryComparison(operand), true
+	}
+	cond, ok := parseOr()
+	skipSpace()
+	if !ok || pos < len(expr) {
+		return nil, false
+	}
+	return cond, true
+}
+
+// scanQueryOperand returns the index where the comparison starting at index `start` of a query
+// expression ends: at the next `&&` or `||`, at an unmatched `)`, or at the end of the expression.
+// Strings and nested brackets, such as the brackets of a nested query, are skipped.
+func scanQueryOperand(expr string, start int) int {
+	depth := 0
+	i := start
+	for ; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			i++
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case '"':
+			i = skipQueryString(expr, i)
+		case '&', '|':
+			if depth == 0 && i+1 < len(expr) && expr[i+1] == expr[i] {
+				return i
+			}
+		}
+	}
+	if i > len(expr) {
+		i = len(expr)
+	}
+	return i
+}
+
+// parseQueryComparison parses a single comparison of a query expression, such as
+// `age<30` or `gender=="female"`, into a leaf of a `queryCondition` tree. A comparison
+// without an operator checks for the existence of the path.
+func parseQueryComparison(expr string) *queryCondition {
+	if path, op, value, ok := splitQueryWordOperator(expr); ok {
+		return &queryCondition{
+			path:      path,
+			option:    op,
+			value:     value,
+			valuePath: queryValuePath(value),
+		}
+	}
+	depth := 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			i++
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+		case '"':
+			i = skipQueryString(expr, i)
+		case '!', '=', '<', '>', '%':
+			if depth == 0 {
+				op, value := splitQueryOperator(trim(expr[i:]))
+				return &queryCondition{
+					path:      trim(expr[:i]),
+					option:    op,
+					value:     unquoteQueryValue(op, value, strings.IndexByte(value, '\\') >= 0),
+					valuePath: queryValuePath(value),
+				}
+			}
+		}
+	}
+	return &queryCondition{path: expr}
+}
+
+// matches evaluates the expression tree of a query against an array element. The operands of
+// `&&` and `||` are evaluated from left to right, and the right operand is only evaluated when
+// the left one does not decide the result.
+//
+// Parameters:
+//   - `elem`: The array element to evaluate.
+//   - `plan`: The compiled path the query belongs to, or nil.
+//
+// Returns:
+//   - `true` if the element satisfies the expression, otherwise `false`.
+func (q *queryCondition) matches(elem Context, plan *pathPlan) bool {
+	switch q.op {
+	case "&&":
+		return q.left.matches(elem, plan) && q.right.matches(elem, plan)
+	case "||":
+		return q.left.matches(elem, plan) || q.right.matches(elem, plan)
+	case "!":
+		return !q.left.matches(elem, plan)
+	}
+	value := elem
+	if q.path != "" || !isQueryLiteralOperator(q.option) {
+		value = resolveQueryField(elem, q.path, plan)
+	}
+	if q.valuePath != "" {
+		return matchesField(q.option, value, resolveQueryField(elem, q.valuePath, plan))
+	}
+	return matchesCondition(q.option, q.value, value)
+}
+
+// resolveQueryField returns the value of a path relative to an array element evaluated by a
+// query. An empty path refers to the element itself, and a non-empty path only exists in
+// objects and arrays.
+//
+// Parameters:
+//   - `elem`: The array element.
+//   - `path`: The path relative to the element.
+//   - `plan`: The compiled path the query belongs to, or nil.
+//
+// Returns:
+//   - The value of the path, which does not exist when the path cannot be resolved.
+func resolveQueryField(elem Context, path string, plan *pathPlan) Context {
+	if elem.kind == JSON {
+		return elem.get(path, plan)
+	}
+	if path == "" {
+		return elem
+	}
+	return Context{}
+}
+
+// matchesField reports whether a value satisfies a query comparison whose right-hand side is
+// another field of the same array element, such as `price_2007>@.price_2002`. The field is
+// compared with the same typed rules as a literal value written in the query, following the
+// type of the left-hand side. Objects and arrays are compared by deep equality with `==` and
+// `!=`, and a field that does not exist or that is `null` never matches. With `in`, `!in` and
+// `contains`, the field provides the JSON value the operator is applied to.
+//
+// Parameters:
+//   - `option`: The comparison operator (e.g., `=`, `<`).
+//   - `value`: The value of the left-hand side.
+//   - `other`: The value of the referenced field.
+//
+// Returns:
+//   - `true` if the value satisfies the comparison, otherwise `false`.
+//
+// Example Usage:
+//
+//	matchesField(">", Parse("56.27"), Parse("44.9")) // true
+func matchesField(option string, value, other Context) bool {
+	switch {
+	case isQueryLiteralOperator(option):
+		if !other.Exists() {
+			return false
+		}
+		return compareCondition(option, other.Unprocessed(), value)
+	case other.kind == JSON:
+		if option != "=" && option != "!=" {
+			return false
+		}
+		return compareCondition(option, other.Unprocessed(), value)
+	case other.kind == String || other.kind == Number || other.kind == True || other.kind == False:
+		return compareCondition(option, other.String(), value)
+	default:
+		return false
+	}
+}
+
+// appendJSON converts a given string into a valid JSON string format
+// and appends it to the provided byte slice `dst`.
+//
+// This function escapes special characters in the input string `s` to ensure
+// that it adheres to the JSON string encoding rules, such as escaping double
+// quotes, backslashes, and control characters. Additionally, it handles UTF-8
+// characters and appends them in their proper encoded format.
+//
+// Parameters:
+//   - dst: A byte slice to which the encoded JSON string will be appended.
+//   - s: The input string to be converted into JSON string format.
+//
+// Returns:
+//   - []byte: The resulting byte slice containing the original content of `dst`
+//     with the JSON-encoded string appended.
+//
+// Details:
+//   - The function begins by appending space for the string `s` and wrapping
+//     it in double quotes.
+//   - It iterates through the input string `s` character by character and checks
+//     for specific cases where escaping or additional encoding is required:
+//   - Control characters (`\n`, `\r`, `\t`) are replaced with their escape
+//     sequences (`\\n`, `\\r`, `\\t`).
+//   - Characters like `<`, `>`, and `&` are escaped using Unicode notation
+//     to ensure the resulting JSON string is safe for embedding in HTML or XML.
+//   - Backslashes (`\`) and double quotes (`"`) are escaped with a preceding
+//     backslash (`\\`).
+//   - UTF-8 characters are properly encoded, and unsupported characters or
+//     decoding errors are replaced with the Unicode replacement character
+//     (`\ufffd`).
+//
+// Example Usage:
+//
+//	dst := []byte("Current JSON: ")
+//	s := "Hello \"world\"\nLine break!"
+//	result := appendJSON(dst, s)
+//	// result: []byte(`Current JSON: "Hello \"world\"\nLine break!"`)
+//
+// Notes:
+//   - This function is useful for building JSON-encoded strings dynamically
+//     without allocating new memory for each operation.
+//   - It ensures that the resulting JSON string is safe and adheres to
+//     encoding rules for use in various contexts such as web APIs or
+//     configuration files.
+func appendJSON(target []byte, s string) []byte {
+	target = append(target, make([]byte, len(s)+2)...)
+	target = append(target[:len(target)-len(s)-2], '"')
+	for i := 0; i < len(s); i++ {
+		if s[i] < ' ' {
+			target = append(target, '\\')
+			switch s[i] {
+			case '\n':
+				target = append(target, 'n')
+			case '\r':
+				target = append(target, 'r')
 			case '\t':
 				target = append(target, 't')
 			default:
@@ -4296,3 +5288,4000 @@ func trimWhitespace(s string) string {
 	s = regexpDupSpaces.ReplaceAllString(s, " ")
 	return s
 }
+
+// splitPathComponents splits a simple fj path into its key and index segments.
+//
+// A simple path is a path that addresses a single location in a JSON document: it is made of
+// object keys and array indexes separated by dots, where the special characters may be escaped
+// with a backslash (`\`). Paths that contain wildcards (`*`, `?`), array queries (`#`), pipes (`|`),
+// or that start a segment with a transformer (`@`), a literal (`!`) or a multi-selector (`[`, `{`)
+// are not simple paths.
+//
+// Parameters:
+//   - `path`: The fj path to split.
+//
+// Returns:
+//   - components: A slice of `pathComponent` values, one for each segment of the path.
+//   - ok: A boolean indicating whether the path is a valid simple path.
+//
+// Example Usage:
+//
+//	components, ok := splitPathComponents(`user.fav\.movie.0`)
+//	// components: [{key: "user"}, {key: "fav.movie"}, {key: "0", index: 0}]
+//	// ok: true
+//
+//	_, ok = splitPathComponents("friends.#.name")
+//	// ok: false (queries cannot address a single location)
+//
+// Notes:
+//   - The segment `-1` is flagged as an append segment, which adds a new element at the end of an array.
+//   - Empty segments (e.g. "a..b" or a trailing dot) are rejected.
+func splitPathComponents(path string) (components []pathComponent, ok bool) {
+	if len(path) == 0 || (len(path) >= 2 && path[0] == '.' && path[1] == '.') {
+		return nil, false
+	}
+	var key []byte
+	var escaped bool
+	push := func() bool {
+		if len(key) == 0 {
+			return false
+		}
+		component := pathComponent{key: string(key), index: -1, escaped: escaped}
+		if n, ok := parseUint64(component.key); ok {
+			component.index = int(n)
+		} else if component.key == "-1" && !escaped {
+			component.append = true
+		}
+		components = append(components, component)
+		key = key[:0]
+		escaped = false
+		return true
+	}
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			i++
+			if i == len(path) {
+				return nil, false
+			}
+			key = append(key, path[i])
+			escaped = true
+			continue
+		case '.':
+			if !push() {
+				return nil, false
+			}
+			continue
+		case '*', '?', '#', '|':
+			return nil, false
+		case '@', '!', '[', '{':
+			if len(key) == 0 {
+				return nil, false
+			}
+		}
+		key = append(key, path[i])
+	}
+	if !push() {
+		return nil, false
+	}
+	return components, true
+}
+
+// encodeJSONValue encodes a Go value into its raw JSON representation.
+//
+// Parameters:
+//   - `value`: The Go value to encode.
+//
+// Returns:
+//   - A string containing the raw JSON encoding of the value.
+//   - An error if the value cannot be represented as JSON (e.g. NaN or infinite numbers,
+//     channels or functions).
+//
+// Details:
+//   - nil is encoded as `null`, booleans as `true`/`false`, and strings with `appendJSON`.
+//   - Integer and floating-point types are formatted with `strconv` to keep their full precision.
+//   - A `Context` is encoded with its unprocessed JSON, or `null` when it does not exist.
+//   - Any other value is encoded with `unify4g.MarshalToStringN` (i.e. `encoding/json`).
+//
+// Example Usage:
+//
+//	raw, _ := encodeJSONValue("Hello \"world\"") // raw: "Hello \"world\"" (quoted and escaped)
+//	raw, _ = encodeJSONValue(42)                  // raw: 42
+//	raw, _ = encodeJSONValue([]int{1, 2})         // raw: [1,2]
+func encodeJSONValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return string(appendJSON(nil, v)), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return "", fmt.Errorf("fj: unsupported number %v", v)
+		}
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("fj: unsupported number %v", v)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case Context:
+		if !v.Exists() {
+			return "null", nil
+		}
+		if len(v.unprocessed) == 0 {
+			return encodeJSONValue(v.Value())
+		}
+		return v.unprocessed, nil
+	}
+	return unify4g.MarshalToStringN(value)
+}
+
+// setRaw stores a raw JSON value at the specified simple path within the provided JSON string.
+//
+// Parameters:
+//   - `json`: The JSON document to modify.
+//   - `path`: A simple fj path (see `splitPathComponents`).
+//   - `raw`: The raw JSON value to store.
+//
+// Returns:
+//   - The updated JSON document.
+//   - `ErrEmptyPath` if the path is empty, `ErrUnsupportedPath` if it is not a simple path,
+//     or an error if a key segment addresses an existing array.
+func setRaw(json, path, raw string) (string, error) {
+	if len(path) == 0 {
+		return json, ErrEmptyPath
+	}
+	components, ok := splitPathComponents(path)
+	if !ok {
+		return json, ErrUnsupportedPath
+	}
+	return setRawAtComponents(json, components, raw)
+}
+
+// setRawAtComponents stores a raw JSON value at the location described by `components` within
+// the provided JSON value, and returns the updated JSON value.
+//
+// The function walks the document one segment at a time. When the segment exists, the function
+// recurses into the child value and splices the result back at the byte offsets reported by
+// `Context.Index()`. When the segment does not exist, a new member (for objects) or a new element
+// (for arrays) is inserted, containing the structure built by `buildRawAtComponents`. When the
+// current value is missing or is not a container, it is replaced entirely by the built structure.
+//
+// Parameters:
+//   - `json`: The JSON value to modify. It may be empty when the value does not exist yet.
+//   - `components`: The remaining path segments to walk.
+//   - `raw`: The raw JSON value to store.
+//
+// Returns:
+//   - The updated JSON value.
+//   - An error if a non-numeric key segment addresses an array.
+//
+// Example Usage:
+//
+//	json, _ := setRawAtComponents(`{"a":{"b":1}}`, []pathComponent{{key: "a", index: -1}, {key: "c", index: -1}}, `2`)
+//	// json: {"a":{"b":1,"c":2}}
+func setRawAtComponents(json string, components []pathComponent, raw string) (string, error) {
+	if len(components) == 0 {
+		return raw, nil
+	}
+	ctx := Parse(json)
+	if !ctx.IsObject() && !ctx.IsArray() {
+		return buildRawAtComponents(components, raw), nil
+	}
+	head := components[0]
+	start := ctx.index
+	ctx.unprocessed = squash(ctx.unprocessed)
+	var found bool
+	var count int
+	last, vStart, vEnd := -1, 0, 0
+	ctx.Foreach(func(key, value Context) bool {
+		last = value.index + len(value.unprocessed)
+		if ctx.IsObject() {
+			found = key.String() == head.key
+		} else {
+			found = !head.append && count == head.index
+		}
+		if found {
+			vStart, vEnd = value.index, last
+			return false
+		}
+		count++
+		return true
+	})
+	if found {
+		child, err := setRawAtComponents(json[vStart:vEnd], components[1:], raw)
+		if err != nil {
+			return json, err
+		}
+		return json[:vStart] + child + json[vEnd:], nil
+	}
+	var member []byte
+	if last >= 0 {
+		member = append(member, ',')
+	} else {
+		last = start + 1
+	}
+	if ctx.IsObject() {
+		member = appendJSON(member, head.key)
+		member = append(member, ':')
+	} else {
+		if !head.append && head.index < 0 {
+			return json, fmt.Errorf("fj: cannot use key %q on an array", head.key)
+		}
+		for ; count < head.index; count++ {
+			member = append(member, "null,"...)
+		}
+	}
+	member = append(member, buildRawAtComponents(components[1:], raw)...)
+	return json[:last] + string(member) + json[last:], nil
+}
+
+// buildRawAtComponents builds a new JSON structure that holds a raw JSON value at the location
+// described by `components`.
+//
+// Parameters:
+//   - `components`: The path segments describing the structure to build.
+//   - `raw`: The raw JSON value to place at the innermost location.
+//
+// Returns:
+//   - A string containing the built JSON structure.
+//
+// Details:
+//   - Numeric segments and the `-1` append segment build arrays, where the element at the given
+//     index is preceded by `null` padding elements.
+//   - Key segments, and numeric segments that were escaped, build objects.
+//
+// Example Usage:
+//
+//	components, _ := splitPathComponents("user.tags.1")
+//	s := buildRawAtComponents(components, `"go"`)
+//	// s: {"user":{"tags":[null,"go"]}}
+func buildRawAtComponents(components []pathComponent, raw string) string {
+	if len(components) == 0 {
+		return raw
+	}
+	head := components[0]
+	child := buildRawAtComponents(components[1:], raw)
+	var b []byte
+	if !head.escaped && (head.append || head.index >= 0) {
+		b = append(b, '[')
+		for i := 0; i < head.index; i++ {
+			b = append(b, "null,"...)
+		}
+		b = append(b, child...)
+		return string(append(b, ']'))
+	}
+	b = append(b, '{')
+	b = appendJSON(b, head.key)
+	b = append(b, ':')
+	b = append(b, child...)
+	return string(append(b, '}'))
+}
+
+// deleteAtContext removes every value referenced by the provided result from the JSON document.
+//
+// Parameters:
+//   - `json`: The JSON document the result was obtained from.
+//   - `ctx`: The result of a `Get` call on `json`.
+//
+// Returns:
+//   - The updated JSON document, or the original document when the result does not exist.
+//   - The error of the result (e.g. a `*PathError`), or `ErrUnaddressablePath` if a referenced
+//     value cannot be located in the document.
+//
+// Details:
+//   - A result with `Indexes()` (e.g. from a `#(...)#` query) references one value per element
+//     of its array; otherwise the result itself is referenced through `Index()`.
+//   - Each value is verified to be present at its offset and to be an object member or an array
+//     element, then removed from the last to the first so that earlier offsets remain valid.
+//   - Values nested inside another removed value are skipped.
+func deleteAtContext(json string, ctx Context) (string, error) {
+	if ctx.err != nil {
+		return json, ctx.err
+	}
+	if !ctx.Exists() {
+		return json, nil
+	}
+	var spans []byteSpan
+	if len(ctx.indexes) > 0 {
+		elements := ctx.Array()
+		if len(elements) != len(ctx.indexes) {
+			return json, ErrUnaddressablePath
+		}
+		for i, element := range elements {
+			spans = append(spans, byteSpan{ctx.indexes[i], ctx.indexes[i] + len(element.unprocessed)})
+		}
+	} else if ctx.IsArray() && ctx.indexes != nil {
+		return json, nil // an empty query result
+	} else {
+		spans = append(spans, byteSpan{ctx.index, ctx.index + len(ctx.unprocessed)})
+	}
+	for i := range spans {
+		s := spans[i]
+		if s.start <= 0 || s.end > len(json) || s.start == s.end || json[s.start:s.end] != trim(json[s.start:s.end]) {
+			return json, ErrUnaddressablePath
+		}
+		start, ok := memberStart(json, s.start)
+		if !ok {
+			return json, ErrUnaddressablePath
+		}
+		spans[i].start = start
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	lowest := len(json) + 1
+	for _, s := range spans {
+		if s.end > lowest {
+			continue // nested inside a span that is removed afterwards
+		}
+		json = removeMember(json, s.start, s.end)
+		lowest = s.start
+	}
+	return json, nil
+}
+
+// memberStart returns the offset at which the object member or array element holding the value
+// that starts at `valueStart` begins.
+//
+// For an object member, this is the offset of the opening quote of its key; for an array element,
+// it is the offset of the value itself.
+//
+// Parameters:
+//   - `json`: The JSON document.
+//   - `valueStart`: The offset of the first byte of the value.
+//
+// Returns:
+//   - The offset of the member or element.
+//   - A boolean indicating whether the value is actually stored inside an object or an array.
+func memberStart(json string, valueStart int) (int, bool) {
+	i := valueStart - 1
+	for i >= 0 && json[i] <= ' ' {
+		i--
+	}
+	if i < 0 {
+		return 0, false
+	}
+	switch json[i] {
+	case ',', '[':
+		return valueStart, true
+	case ':':
+	default:
+		return 0, false
+	}
+	i--
+	for i >= 0 && json[i] <= ' ' {
+		i--
+	}
+	if i < 1 || json[i] != '"' {
+		return 0, false
+	}
+	for i--; i >= 0; i-- {
+		if json[i] != '"' {
+			continue
+		}
+		n := 0
+		for j := i - 1; j >= 0 && json[j] == '\\'; j-- {
+			n++
+		}
+		if n%2 == 0 {
+			break
+		}
+	}
+	if i < 0 {
+		return 0, false
+	}
+	key := i
+	for i--; i >= 0 && json[i] <= ' '; i-- {
+	}
+	if i < 0 || (json[i] != '{' && json[i] != ',') {
+		return 0, false
+	}
+	return key, true
+}
+
+// removeMember removes the object member or array element located at `[start, end)` from the JSON
+// document, together with the comma that separates it from its siblings.
+//
+// Parameters:
+//   - `json`: The JSON document.
+//   - `start`: The offset of the member (see `memberStart`).
+//   - `end`: The offset just past the end of the member's value.
+//
+// Returns:
+//   - The updated JSON document.
+//
+// Example Usage:
+//
+//	removeMember(`{"a":1, "b":2}`, 1, 6) // {"b":2}
+//	removeMember(`[1, 2]`, 4, 5)         // [1]
+//	removeMember(`[1]`, 1, 2)            // []
+func removeMember(json string, start, end int) string {
+	next := end
+	for next < len(json) && json[next] <= ' ' {
+		next++
+	}
+	if next < len(json) && json[next] == ',' {
+		next++
+		for next < len(json) && json[next] <= ' ' {
+			next++
+		}
+		return json[:start] + json[next:]
+	}
+	prev := start - 1
+	for prev >= 0 && json[prev] <= ' ' {
+		prev--
+	}
+	if prev >= 0 && json[prev] == ',' {
+		return json[:prev] + json[end:]
+	}
+	return json[:prev+1] + json[next:]
+}
+
+// decodeContext stores the JSON value held by `ctx` into the Go value `rv`.
+//
+// This is the recursive worker behind `Context.Unmarshal`. It dispatches on the kind of the Go
+// value: pointers are allocated, types implementing `UnmarshalJSON` or `encoding.TextUnmarshaler`
+// decode themselves, and structs, maps, slices, arrays and scalars are populated from the matching
+// JSON kinds.
+//
+// Parameters:
+//   - `ctx`: The JSON value to decode.
+//   - `rv`: A settable `reflect.Value` that receives the decoded value.
+//   - `path`: The fj path of `ctx` relative to the decoded root, used in error messages.
+//
+// Returns:
+//   - An error if the JSON value cannot be stored in `rv`.
+func decodeContext(ctx Context, rv reflect.Value, path string) error {
+	if ctx.kind == Null {
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	}
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeContext(ctx, rv.Elem(), path)
+	}
+	if rv.Type() == reflect.TypeOf(time.Time{}) {
+		return decodeTime(ctx, rv, path)
+	}
+	if rv.CanAddr() {
+		switch u := rv.Addr().Interface().(type) {
+		case interface{ UnmarshalJSON([]byte) error }:
+			if err := u.UnmarshalJSON([]byte(ctx.unprocessed)); err != nil {
+				return unmarshalPathError(path, err)
+			}
+			return nil
+		case encoding.TextUnmarshaler:
+			if ctx.kind != String {
+				return unmarshalTypeError(ctx, rv.Type(), path)
+			}
+			if err := u.UnmarshalText([]byte(ctx.strings)); err != nil {
+				return unmarshalPathError(path, err)
+			}
+			return nil
+		}
+	}
+	switch rv.Kind() {
+	case reflect.Interface:
+		if !rv.IsNil() && rv.Elem().Kind() == reflect.Ptr && !rv.Elem().IsNil() {
+			return decodeContext(ctx, rv.Elem(), path)
+		}
+		if rv.NumMethod() > 0 {
+			return unmarshalTypeError(ctx, rv.Type(), path)
+		}
+		rv.Set(reflect.ValueOf(ctx.Value()))
+	case reflect.Struct:
+		return decodeStruct(ctx, rv, path)
+	case reflect.Map:
+		return decodeMap(ctx, rv, path)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 && ctx.kind == String {
+			b, err := base64.StdEncoding.DecodeString(ctx.strings)
+			if err != nil {
+				return unmarshalPathError(path, err)
+			}
+			rv.SetBytes(b)
+			return nil
+		}
+		if !ctx.IsArray() {
+			return unmarshalTypeError(ctx, rv.Type(), path)
+		}
+		elements := ctx.Array()
+		s := reflect.MakeSlice(rv.Type(), len(elements), len(elements))
+		for i, element := range elements {
+			if err := decodeContext(element, s.Index(i), joinPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		rv.Set(s)
+	case reflect.Array:
+		if !ctx.IsArray() {
+			return unmarshalTypeError(ctx, rv.Type(), path)
+		}
+		elements := ctx.Array()
+		for i := 0; i < rv.Len(); i++ {
+			if i >= len(elements) {
+				rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+				continue
+			}
+			if err := decodeContext(elements[i], rv.Index(i), joinPath(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		if ctx.kind != String {
+			return unmarshalTypeError(ctx, rv.Type(), path)
+		}
+		rv.SetString(ctx.strings)
+	case reflect.Bool:
+		if ctx.kind != True && ctx.kind != False {
+			return unmarshalTypeError(ctx, rv.Type(), path)
+		}
+		rv.SetBool(ctx.kind == True)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := integralInt64(ctx)
+		if !ok || rv.OverflowInt(n) {
+			if ctx.kind == Number && ctx.numeric == math.Trunc(ctx.numeric) {
+				return unmarshalOverflowError(ctx, rv.Type(), path)
+			}
+			return unmarshalTypeError(ctx, rv.Type(), path)
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := integralUint64(ctx)
+		if !ok || rv.OverflowUint(n) {
+			if ctx.kind == Number && ctx.numeric == math.Trunc(ctx.numeric) && ctx.numeric >= 0 {
+				return unmarshalOverflowError(ctx, rv.Type(), path)
+			}
+			return unmarshalTypeError(ctx, rv.Type(), path)
+		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		if ctx.kind != Number || rv.OverflowFloat(ctx.numeric) {
+			return unmarshalTypeError(ctx, rv.Type(), path)
+		}
+		rv.SetFloat(ctx.numeric)
+	default:
+		return unmarshalTypeError(ctx, rv.Type(), path)
+	}
+	return nil
+}
+
+// decodeStruct populates the struct `rv` from the JSON object held by `ctx`.
+//
+// Object members are matched against the fields returned by `unmarshalFields`, first by exact
+// name and then case-insensitively. Fields with an `fj` tag are populated afterwards with the
+// result of their path, evaluated against `ctx`.
+//
+// Parameters:
+//   - `ctx`: The JSON object to decode.
+//   - `rv`: A settable struct value.
+//   - `path`: The fj path of `ctx`, used in error messages.
+//
+// Returns:
+//   - An error if `ctx` is not an object, or if a member cannot be stored in its field.
+func decodeStruct(ctx Context, rv reflect.Value, path string) error {
+	if !ctx.IsObject() {
+		return unmarshalTypeError(ctx, rv.Type(), path)
+	}
+	fields := unmarshalFields(rv.Type())
+	var err error
+	ctx.Foreach(func(key, value Context) bool {
+		name := key.String()
+		field := lookupUnmarshalField(fields, name)
+		if field == nil {
+			return true
+		}
+		err = decodeContext(value, fieldByIndexAlloc(rv, field.index), joinPath(path, escapeUnsafeChars(name)))
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if len(field.path) == 0 {
+			continue
+		}
+		value := ctx.Get(field.path)
+		if !value.Exists() {
+			continue
+		}
+		if err := decodeContext(value, fieldByIndexAlloc(rv, field.index), joinPath(path, field.path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeMap populates the map `rv` from the JSON object held by `ctx`, allocating the map when
+// it is nil.
+//
+// Parameters:
+//   - `ctx`: The JSON object to decode.
+//   - `rv`: A settable map value whose key type is a string, an integer, or implements
+//     `encoding.TextUnmarshaler`.
+//   - `path`: The fj path of `ctx`, used in error messages.
+//
+// Returns:
+//   - An error if `ctx` is not an object, if a key cannot be converted to the key type, or if a
+//     member cannot be stored in the element type.
+func decodeMap(ctx Context, rv reflect.Value, path string) error {
+	if !ctx.IsObject() {
+		return unmarshalTypeError(ctx, rv.Type(), path)
+	}
+	t := rv.Type()
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(t))
+	}
+	var err error
+	ctx.Foreach(func(key, value Context) bool {
+		name := key.String()
+		memberPath := joinPath(path, escapeUnsafeChars(name))
+		var k reflect.Value
+		if k, err = decodeMapKey(name, t.Key(), memberPath); err != nil {
+			return false
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if err = decodeContext(value, elem, memberPath); err != nil {
+			return false
+		}
+		rv.SetMapIndex(k, elem)
+		return true
+	})
+	return err
+}
+
+// decodeMapKey converts an object key into a value of the map key type `t`.
+//
+// Parameters:
+//   - `key`: The object key.
+//   - `t`: The map key type.
+//   - `path`: The fj path of the member, used in error messages.
+//
+// Returns:
+//   - The converted key.
+//   - An error if the key cannot be represented by `t`.
+func decodeMapKey(key string, t reflect.Type, path string) (reflect.Value, error) {
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()) {
+		k := reflect.New(t)
+		if err := k.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+			return k, unmarshalPathError(path, err)
+		}
+		return k.Elem(), nil
+	}
+	k := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		k.SetString(key)
+		return k, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(key, 10, 64)
+		if err == nil && !k.OverflowInt(n) {
+			k.SetInt(n)
+			return k, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(key, 10, 64)
+		if err == nil && !k.OverflowUint(n) {
+			k.SetUint(n)
+			return k, nil
+		}
+	}
+	return k, fmt.Errorf("fj: cannot unmarshal object key %q into Go value of type %s at path %q", key, t, path)
+}
+
+// decodeTime populates the `time.Time` value `rv` from a JSON string in RFC 3339 format, or from a
+// JSON number of seconds (with an optional fraction) since the Unix epoch.
+//
+// Parameters:
+//   - `ctx`: The JSON value to decode.
+//   - `rv`: A settable `time.Time` value.
+//   - `path`: The fj path of `ctx`, used in error messages.
+//
+// Returns:
+//   - An error if the value is neither a string nor a number, or if the string is not a valid
+//     RFC 3339 timestamp.
+func decodeTime(ctx Context, rv reflect.Value, path string) error {
+	switch ctx.kind {
+	case String:
+		t, err := time.Parse(time.RFC3339Nano, ctx.strings)
+		if err != nil {
+			return unmarshalPathError(path, err)
+		}
+		rv.Set(reflect.ValueOf(t))
+	case Number:
+		sec, frac := math.Modf(ctx.numeric)
+		rv.Set(reflect.ValueOf(time.Unix(int64(sec), int64(frac*1e9)).UTC()))
+	default:
+		return unmarshalTypeError(ctx, rv.Type(), path)
+	}
+	return nil
+}
+
+// integralInt64 returns the value of a JSON number as an int64, provided the number is integral
+// and fits in an int64 (e.g. `42`, `-7` or `1e3`, but not `1.5` or `9223372036854775808`).
+// Integers written without a fraction or an exponent are parsed exactly, and rejected when they
+// overflow instead of wrapping around.
+//
+// Parameters:
+//   - `ctx`: The JSON value to convert.
+//
+// Returns:
+//   - The integer value.
+//   - A boolean indicating whether the value is an integral number within range.
+func integralInt64(ctx Context) (int64, bool) {
+	if ctx.kind != Number {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(ctx.unprocessed, 10, 64)
+	if err == nil {
+		return n, true
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, false
+	}
+	f := ctx.numeric
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// integralUint64 returns the value of a JSON number as a uint64, provided the number is integral,
+// non-negative and fits in a uint64.
+//
+// Parameters:
+//   - `ctx`: The JSON value to convert.
+//
+// Returns:
+//   - The unsigned integer value.
+//   - A boolean indicating whether the value is a non-negative integral number within range.
+func integralUint64(ctx Context) (uint64, bool) {
+	if ctx.kind != Number {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(ctx.unprocessed, 10, 64)
+	if err == nil {
+		return n, true
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, false
+	}
+	f := ctx.numeric
+	if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+		return 0, false
+	}
+	return uint64(f), true
+}
+
+// unmarshalFields returns the fields of the struct type `t` that can be populated by
+// `Context.Unmarshal`, caching the result in `unmarshalFieldCache`.
+//
+// Exported fields are collected along with the promoted fields of embedded structs. Fields
+// tagged with `json:"-"` or `fj:"-"` are ignored. The result is ordered by depth, so that the
+// fields of the outer struct take precedence over promoted fields with the same name.
+//
+// Parameters:
+//   - `t`: A struct type.
+//
+// Returns:
+//   - A slice of `unmarshalField` values describing the fields of `t`.
+func unmarshalFields(t reflect.Type) []unmarshalField {
+	if fields, ok := unmarshalFieldCache.Load(t); ok {
+		return fields.([]unmarshalField)
+	}
+	fields := collectUnmarshalFields(t, nil, map[reflect.Type]bool{})
+	sort.SliceStable(fields, func(i, j int) bool { return len(fields[i].index) < len(fields[j].index) })
+	cached, _ := unmarshalFieldCache.LoadOrStore(t, fields)
+	return cached.([]unmarshalField)
+}
+
+// collectUnmarshalFields collects the fields of the struct type `t` (see `unmarshalFields`).
+//
+// Parameters:
+//   - `t`: A struct type.
+//   - `parent`: The index sequence of `t` within the outermost struct.
+//   - `visited`: The embedded struct types already being collected, to stop on recursive types.
+//
+// Returns:
+//   - A slice of `unmarshalField` values describing the fields of `t`.
+func collectUnmarshalFields(t reflect.Type, parent []int, visited map[reflect.Type]bool) (fields []unmarshalField) {
+	visited[t] = true
+	defer delete(visited, t)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		jsonTag, fjTag := sf.Tag.Get("json"), sf.Tag.Get("fj")
+		if jsonTag == "-" || fjTag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(jsonTag, ",")
+		index := append(append([]int(nil), parent...), i)
+		if sf.Anonymous && len(name) == 0 && len(fjTag) == 0 {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if (!sf.IsExported() && sf.Type.Kind() == reflect.Ptr) || visited[ft] {
+					continue
+				}
+				fields = append(fields, collectUnmarshalFields(ft, index, visited)...)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = sf.Name
+		}
+		fields = append(fields, unmarshalField{name: name, path: fjTag, index: index})
+	}
+	return fields
+}
+
+// lookupUnmarshalField returns the field matching the object key `name`, preferring an exact match
+// over a case-insensitive one. Fields with an `fj` tag never match object keys.
+//
+// Parameters:
+//   - `fields`: The fields returned by `unmarshalFields`.
+//   - `name`: The object key.
+//
+// Returns:
+//   - A pointer to the matching field, or nil when no field matches.
+func lookupUnmarshalField(fields []unmarshalField, name string) *unmarshalField {
+	for i := range fields {
+		if len(fields[i].path) == 0 && fields[i].name == name {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if len(fields[i].path) == 0 && strings.EqualFold(fields[i].name, name) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
+
+// fieldByIndexAlloc returns the nested field of the struct `v` described by `index`, allocating
+// the nil pointers to embedded structs along the way.
+//
+// Parameters:
+//   - `v`: A settable struct value.
+//   - `index`: The index sequence of the field.
+//
+// Returns:
+//   - The settable field value.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// joinPath appends the path `component` to the fj path `parent`, separated by a dot.
+//
+// Parameters:
+//   - `parent`: The parent path, which may be empty.
+//   - `component`: The (escaped) path component to append.
+//
+// Returns:
+//   - The joined path.
+//
+// Example Usage:
+//
+//	joinPath("", "user")      // "user"
+//	joinPath("user", "roles") // "user.roles"
+func joinPath(parent, component string) string {
+	if len(parent) == 0 {
+		return component
+	}
+	return parent + "." + component
+}
+
+// unmarshalTypeError returns the error reported when the JSON value `ctx` cannot be stored in a
+// Go value of type `t`.
+func unmarshalTypeError(ctx Context, t reflect.Type, path string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("fj: cannot unmarshal %s into Go value of type %s", ctx.kind, t)
+	}
+	return fmt.Errorf("fj: cannot unmarshal %s into Go value of type %s at path %q", ctx.kind, t, path)
+}
+
+// unmarshalOverflowError returns the error reported when an integral JSON number does not fit in
+// the integer type `t`, as `encoding/json` does.
+func unmarshalOverflowError(ctx Context, t reflect.Type, path string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("fj: number %s overflows %s", ctx.unprocessed, t)
+	}
+	return fmt.Errorf("fj: number %s overflows %s at path %q", ctx.unprocessed, t, path)
+}
+
+// unmarshalPathError wraps an error returned while decoding the value at `path`.
+func unmarshalPathError(path string, err error) error {
+	if len(path) == 0 {
+		return fmt.Errorf("fj: %w", err)
+	}
+	return fmt.Errorf("fj: path %q: %w", path, err)
+}
+
+// convertAs converts the value held by `ctx` to the Go type `T` (see `As`).
+//
+// Parameters:
+//   - `ctx`: The value to convert.
+//   - `path`: The fj path that produced `ctx`, reported in errors.
+//
+// Returns:
+//   - The converted value, or the zero value of `T` on failure.
+//   - A `*TypeError` when the value does not exist or cannot be converted. The decoding error is
+//     attached to the `*TypeError` only when it concerns a nested value of a composite target.
+func convertAs[T any](ctx Context, path string) (T, error) {
+	var out T
+	target := reflect.TypeOf(&out).Elem()
+	if ctx.err != nil {
+		return out, ctx.err
+	}
+	if !ctx.Exists() {
+		return out, &TypeError{Path: path, Kind: ctx.kind, Target: target, Missing: true}
+	}
+	if p, ok := any(&out).(*Context); ok {
+		*p = ctx
+		return out, nil
+	}
+	if !acceptsKind(ctx, target) {
+		return out, &TypeError{Path: path, Kind: ctx.kind, Target: target}
+	}
+	if err := decodeContext(ctx, reflect.ValueOf(&out).Elem(), path); err != nil {
+		var zero T
+		return zero, &TypeError{Path: path, Kind: ctx.kind, Target: target, Err: err}
+	}
+	return out, nil
+}
+
+// acceptsKind reports whether the JSON type of `ctx` can be converted to the Go type `t`, without
+// looking at nested values.
+//
+// Parameters:
+//   - `ctx`: The value to convert.
+//   - `t`: The target Go type.
+//
+// Returns:
+//   - A boolean indicating whether the top-level JSON type matches the Go type.
+func acceptsKind(ctx Context, t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return ctx.kind == Null || acceptsKind(ctx, t.Elem())
+	case reflect.Interface:
+		return true
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return ctx.kind == String || ctx.kind == Number
+	}
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*interface{ UnmarshalJSON([]byte) error })(nil)).Elem()) {
+		return true
+	}
+	if reflect.PtrTo(t).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()) {
+		return ctx.kind == String
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return ctx.kind == Null || ctx.IsObject()
+	case reflect.Slice:
+		return ctx.kind == Null || ctx.IsArray() || (ctx.kind == String && t.Elem().Kind() == reflect.Uint8)
+	case reflect.Struct:
+		return ctx.IsObject()
+	case reflect.Array:
+		return ctx.IsArray()
+	case reflect.String:
+		return ctx.kind == String
+	case reflect.Bool:
+		return ctx.kind == True || ctx.kind == False
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := integralInt64(ctx)
+		return ok && !reflect.New(t).Elem().OverflowInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := integralUint64(ctx)
+		return ok && !reflect.New(t).Elem().OverflowUint(n)
+	case reflect.Float32, reflect.Float64:
+		return ctx.kind == Number && !reflect.New(t).Elem().OverflowFloat(ctx.numeric)
+	}
+	return false
+}
+
+// get is the implementation of `Get`. When `plan` is not nil, the path-parsing functions are
+// replaced by lookups of the segments pre-parsed by `Compile`, and `plan` is passed down to every
+// nested evaluation of a sub-path.
+//
+// Parameters:
+//   - `json`: The JSON string to search.
+//   - `path`: The path to evaluate.
+//   - `plan`: The pre-parsed segments of a compiled path, or nil.
+//
+// Returns:
+//   - A `Context` holding the result, as described in `Get`.
+func get(json, path string, plan *pathPlan) Context {
+	if len(path) > 1 {
+		if (path[0] == '@' && !DisableTransformers) || path[0] == '!' {
+			var ok bool
+			var cPath string
+			var cJson string
+			if seg := plan.lookup(path); seg != nil {
+				if path[0] == '@' && !DisableTransformers {
+					if seg.transformer != nil {
+						cPath, cJson, ok = seg.transformer.pathYield, seg.transformer.fn(json, seg.transformer.args), true
+					}
+				} else if path[0] == '!' {
+					cPath, cJson, ok = seg.pathStatic, seg.static, seg.staticOk
+				}
+			} else if path[0] == '@' && !DisableTransformers {
+				cPath, cJson, ok = adjustTransformer(json, path)
+			} else if path[0] == '!' {
+				cPath, cJson, ok = parseStaticSegment(path)
+			}
+			if ok {
+				path = cPath
+				if len(path) > 0 && (path[0] == '|' || path[0] == '.') {
+					res := get(cJson, path[1:], plan)
+					res.index = 0
+					res.indexes = nil
+					return res
+				}
+				return Parse(cJson)
+			}
+		}
+		if isDeepWildcard(path) {
+			return deepWildcard(Parse(json), path[2:], plan)
+		}
+		if bounds, rest, ok := parseSliceSelector(path); ok {
+			return sliceContext(Parse(json), bounds, rest, plan)
+		}
+		if path[0] == '[' || path[0] == '{' {
+			kind := path[0] // using a sub-selector path
+			var ok bool
+			var subs []subSelector
+			if seg := plan.lookup(path); seg != nil {
+				subs, path, ok = seg.selectors, seg.selectorsOut, seg.selectorsOk
+			} else {
+				subs, path, ok = analyzeSubSelectors(path)
+			}
+			if ok {
+				if len(path) == 0 || (path[0] == '|' || path[0] == '.') {
+					var b []byte
+					b = append(b, kind)
+					var i int
+					for _, sub := range subs {
+						res := get(json, sub.path, plan)
+						if res.Exists() {
+							if i > 0 {
+								b = append(b, ',')
+							}
+							if kind == '{' {
+								if len(sub.name) > 0 {
+									if sub.name[0] == '"' && IsValidJSON(sub.name) {
+										b = append(b, sub.name...)
+									} else {
+										b = appendJSON(b, sub.name)
+									}
+								} else {
+									last := lastSegment(sub.path)
+									if isValidName(last) {
+										b = appendJSON(b, last)
+									} else {
+										b = appendJSON(b, "_")
+									}
+								}
+								b = append(b, ':')
+							}
+							var raw string
+							if len(res.unprocessed) == 0 {
+								raw = res.String()
+								if len(raw) == 0 {
+									raw = "null"
+								}
+							} else {
+								raw = res.unprocessed
+							}
+							b = append(b, raw...)
+							i++
+						}
+					}
+					b = append(b, kind+2)
+					var res Context
+					res.unprocessed = string(b)
+					res.kind = JSON
+					if len(path) > 0 {
+						res = res.get(path[1:], plan)
+					}
+					res.index = 0
+					return res
+				}
+			}
+		}
+	}
+	var i int
+	var c = &parser{json: json, plan: plan}
+	if len(path) >= 2 && path[0] == '.' && path[1] == '.' {
+		c.lines = true
+		analyzeArray(c, 0, path[2:])
+	} else {
+		for ; i < len(c.json); i++ {
+			if c.json[i] == '{' {
+				i++
+				parseJSONObject(c, i, path)
+				break
+			}
+			if c.json[i] == '[' {
+				i++
+				analyzeArray(c, i, path)
+				break
+			}
+		}
+	}
+	if c.piped {
+		if isDeepWildcard(c.pipe) {
+			computeIndex(json, c)
+			return deepWildcard(c.value, c.pipe[2:], plan)
+		}
+		if bounds, rest, ok := parseSliceSelector(c.pipe); ok {
+			computeIndex(json, c)
+			return sliceContext(c.value, bounds, rest, plan)
+		}
+		res := c.value.get(c.pipe, plan)
+		res.index = 0
+		return res
+	}
+	computeIndex(json, c)
+	return c.value
+}
+
+// offset returns the offset of `path` within the source of the plan.
+//
+// Parameters:
+//   - `path`: A path string, usually a substring of the source.
+//
+// Returns:
+//   - The offset of the first byte of `path` within the source.
+//   - A boolean indicating whether `path` is a non-empty substring of the source.
+func (p *pathPlan) offset(path string) (int, bool) {
+	if p == nil || len(path) == 0 {
+		return 0, false
+	}
+	source := *(*stringHeader)(unsafe.Pointer(&p.source))
+	sub := *(*stringHeader)(unsafe.Pointer(&path))
+	if uintptr(sub.data) < uintptr(source.data) {
+		return 0, false
+	}
+	offset := int(uintptr(sub.data) - uintptr(source.data))
+	if offset+len(path) > len(p.source) {
+		return 0, false
+	}
+	return offset, true
+}
+
+// lookup returns the pre-parsed segment for `path`.
+//
+// Parameters:
+//   - `path`: A path string received by one of the evaluation functions.
+//
+// Returns:
+//   - The pre-parsed segment, or nil when the plan is nil or `path` is not a compiled substring
+//     of the source (in which case the caller parses `path` itself).
+func (p *pathPlan) lookup(path string) *compiledSegment {
+	offset, ok := p.offset(path)
+	if !ok {
+		return nil
+	}
+	if offset+len(path) == len(p.source) {
+		return p.suffixes[offset]
+	}
+	return p.inner[byteSpan{offset, offset + len(path)}]
+}
+
+// splitPathPipe returns the result of `splitPathPipe` for `path`, from the plan when the segment
+// was pre-parsed.
+func (p *pathPlan) splitPathPipe(path string) (left, right string, ok bool) {
+	if seg := p.lookup(path); seg != nil {
+		return seg.left, seg.right, seg.split
+	}
+	return splitPathPipe(path)
+}
+
+// segment returns the pre-parsed segment for `path`, parsing and storing it when needed.
+//
+// Parameters:
+//   - `path`: A non-empty substring of the source.
+//
+// Returns:
+//   - The pre-parsed segment.
+func (p *pathPlan) segment(path string) *compiledSegment {
+	if seg := p.lookup(path); seg != nil {
+		return seg
+	}
+	offset, _ := p.offset(path)
+	seg := &compiledSegment{
+		wildcard: parsePathWithTransformers(path),
+		analysis: analyzePath(path),
+	}
+	if !seg.analysis.Arch {
+		if n, ok := parseUint64(seg.analysis.Part); ok {
+			seg.partIdx = int(n)
+		} else {
+			seg.partIdx = -1
+		}
+	}
+	seg.left, seg.right, seg.split = splitPathPipe(path)
+	if len(path) > 1 {
+		switch path[0] {
+		case '@':
+			if fn, args, pathYield, ok := parseTransformer(path); ok {
+				seg.transformer = &compiledTransformer{fn: fn, args: args, pathYield: pathYield}
+			}
+		case '!':
+			seg.pathStatic, seg.static, seg.staticOk = parseStaticSegment(path)
+		case '[', '{':
+			seg.selectors, seg.selectorsOut, seg.selectorsOk = analyzeSubSelectors(path)
+		}
+	}
+	if offset+len(path) == len(p.source) {
+		p.suffixes[offset] = seg
+	} else {
+		p.inner[byteSpan{offset, offset + len(path)}] = seg
+	}
+	return seg
+}
+
+// compilePath parses a path into a plan, walking every sub-path that the evaluation functions can
+// receive while evaluating the path against a JSON document, and reports the syntax errors found
+// along the way.
+//
+// The walk mirrors `get`, `parseJSONObject` and `analyzeArray`: since the type of each value is
+// only known when a document is evaluated, both the object and the array interpretations of a
+// sub-path are visited.
+//
+// Parameters:
+//   - `path`: The path to compile.
+//
+// Returns:
+//   - The plan holding the pre-parsed segments of the path.
+//   - An error describing the first syntax error found: an unbalanced query (`#(`), an invalid
+//     literal after '!', an unknown transformer, an unbalanced multi-selector (`[` or `{`), or an
+//     invalid slice.
+func compilePath(path string) (*pathPlan, error) {
+	plan := &pathPlan{
+		source:   path,
+		suffixes: make([]*compiledSegment, len(path)),
+		inner:    make(map[byteSpan]*compiledSegment),
+	}
+	type visit struct {
+		span byteSpan
+		kind byte
+	}
+	visited := make(map[visit]bool)
+	enter := func(p string, kind byte) bool {
+		offset, ok := plan.offset(p)
+		if !ok {
+			return false
+		}
+		v := visit{byteSpan{offset, offset + len(p)}, kind}
+		if visited[v] {
+			return false
+		}
+		visited[v] = true
+		return true
+	}
+	fail := func(p string, reason string) error {
+		offset, _ := plan.offset(p)
+		return newPathError(path, offset, reason)
+	}
+	var visitGet, visitObject, visitArray func(p string) error
+
+	// strict reports whether `p` starts a path, a sub-path or follows a pipe, the positions where
+	// an unknown transformer or an invalid literal is reported. Elsewhere, `get` looks them up as
+	// keys. The value paths of queries (`@.key`) start after a dot, so they are recorded in `roots`.
+	roots := make(map[int]bool)
+	strict := func(p string) bool {
+		offset, _ := plan.offset(p)
+		return offset == 0 || path[offset-1] != '.' || roots[offset]
+	}
+	visitRoot := func(p string) error {
+		if offset, ok := plan.offset(p); ok {
+			roots[offset] = true
+		}
+		return visitGet(p)
+	}
+	checkComparison := func(p, option, value string) error {
+		switch option {
+		case "=~", "!~":
+			if _, err := compileQueryRegexp(value); err != nil {
+				return fail(p, "invalid regular expression")
+			}
+		case "in", "!in":
+			if queryValuePath(value) == "" && !parseQueryLiteral(value).IsArray() {
+				return fail(p, "invalid array literal")
+			}
+		}
+		return nil
+	}
+	var visitCondition func(q *queryCondition) error
+	visitCondition = func(q *queryCondition) error {
+		if q == nil {
+			return nil
+		}
+		if q.op == "" {
+			if err := checkComparison(q.path, q.option, q.value); err != nil {
+				return err
+			}
+			if q.valuePath != "" {
+				if err := visitRoot(q.valuePath); err != nil {
+					return err
+				}
+			}
+			return visitGet(q.path)
+		}
+		if err := visitCondition(q.left); err != nil {
+			return err
+		}
+		return visitCondition(q.right)
+	}
+	checkTransformer := func(p string) error {
+		if len(p) > 0 && p[0] == '@' && !DisableTransformers && plan.segment(p).transformer == nil && strict(p) {
+			return fail(p, "unknown transformer")
+		}
+		return nil
+	}
+	visitGet = func(p string) error {
+		if !enter(p, 'g') {
+			return nil
+		}
+		if isDeepWildcard(p) {
+			if len(p) > 3 {
+				return visitGet(p[3:])
+			}
+			return nil
+		}
+		seg := plan.segment(p)
+		if len(p) > 1 {
+			if p[0] == '@' && !DisableTransformers {
+				if seg.transformer != nil {
+					if rest := seg.transformer.pathYield; len(rest) > 0 && (rest[0] == '|' || rest[0] == '.') {
+						return visitGet(rest[1:])
+					}
+					return nil
+				}
+				if strict(p) {
+					return fail(p, "unknown transformer")
+				}
+			}
+			if p[0] == '!' {
+				if seg.staticOk {
+					if rest := seg.pathStatic; len(rest) > 0 && (rest[0] == '|' || rest[0] == '.') {
+						return visitGet(rest[1:])
+					}
+					return nil
+				}
+				if strict(p) {
+					return fail(p, "invalid literal")
+				}
+			}
+			if p[0] == '[' && isSliceLike(p) {
+				if _, rest, ok := parseSliceSelector(p); !ok {
+					return fail(p, "invalid slice")
+				} else if len(rest) > 0 {
+					return visitGet(rest[1:])
+				}
+				return nil
+			}
+			if p[0] == '[' || p[0] == '{' {
+				if !seg.selectorsOk {
+					return fail(p, "unbalanced multi-selector")
+				}
+				if out := seg.selectorsOut; len(out) == 0 || out[0] == '|' || out[0] == '.' {
+					for _, sub := range seg.selectors {
+						if err := visitGet(sub.path); err != nil {
+							return err
+						}
+					}
+					if len(out) > 0 {
+						return visitGet(out[1:])
+					}
+					return nil
+				}
+			}
+		}
+		if len(p) >= 2 && p[0] == '.' && p[1] == '.' {
+			return visitArray(p[2:])
+		}
+		if err := visitObject(p); err != nil {
+			return err
+		}
+		return visitArray(p)
+	}
+	visitObject = func(p string) error {
+		if !enter(p, 'o') {
+			return nil
+		}
+		if err := checkTransformer(p); err != nil {
+			return err
+		}
+		w := plan.segment(p).wildcard
+		if !w.More && w.Piped {
+			return visitGet(w.Pipe)
+		}
+		if w.More {
+			if err := visitObject(w.Path); err != nil {
+				return err
+			}
+			return visitArray(w.Path)
+		}
+		return nil
+	}
+	visitArray = func(p string) error {
+		if !enter(p, 'a') {
+			return nil
+		}
+		if err := checkTransformer(p); err != nil {
+			return err
+		}
+		a := plan.segment(p).analysis
+		if !a.More && a.Piped {
+			if err := visitGet(a.Pipe); err != nil {
+				return err
+			}
+		}
+		visitSplit := func(sp string) error {
+			if len(sp) == 0 {
+				return nil
+			}
+			seg := plan.segment(sp)
+			if !seg.split {
+				return visitGet(sp)
+			}
+			if err := visitGet(seg.left); err != nil {
+				return err
+			}
+			return visitGet(seg.right)
+		}
+		switch {
+		case a.query.On:
+			_, _, _, _, fi, _, ok := analyzeQuery(p)
+			if !ok {
+				return fail(p, "unbalanced query")
+			}
+			if expr := p[2 : fi-1]; isLogicalQuery(expr) {
+				cond, ok := parseQueryConditions(expr)
+				if !ok {
+					return fail(expr, "invalid query expression")
+				}
+				if err := visitCondition(cond); err != nil {
+					return err
+				}
+			} else {
+				if err := checkComparison(p, a.query.Option, a.query.Value); err != nil {
+					return err
+				}
+				if a.query.ValuePath != "" {
+					if err := visitRoot(a.query.ValuePath); err != nil {
+						return err
+					}
+				}
+				if err := visitGet(a.query.QueryPath); err != nil {
+					return err
+				}
+			}
+			if a.More {
+				return visitSplit(a.Path)
+			}
+		case a.ALogOk:
+			return visitSplit(a.ALogKey)
+		case !a.Arch && a.More:
+			if err := visitObject(a.Path); err != nil {
+				return err
+			}
+			return visitArray(a.Path)
+		}
+		return nil
+	}
+	if err := visitGet(path); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// newPathError returns the error reported for a syntax error in a path.
+//
+// Parameters:
+//   - `path`: The path that contains the error.
+//   - `offset`: The byte offset of the error within the path.
+//   - `reason`: A short description of the error.
+//
+// Returns:
+//   - A `*PathError` describing the syntax error.
+func newPathError(path string, offset int, reason string) error {
+	return &PathError{Path: path, Offset: offset, Reason: reason}
+}
+
+// withPathError attaches the syntax error of `path`, if any, to the result of evaluating it.
+//
+// The path is only validated when it contains one of the characters that start a query, a
+// transformer, a literal or a multi-selector, so that plain paths are not slowed down. A malformed
+// path can still produce a partial result (e.g. `bank.#(phone=~"(")#.name` yields `[]`), so the
+// error is attached whether or not the result exists.
+//
+// Parameters:
+//   - `res`: The result of evaluating `path`.
+//   - `path`: The evaluated path.
+//
+// Returns:
+//   - The result, with its error set to a `*PathError` when the path is malformed.
+//
+// Notes:
+//   - An unknown transformer or an invalid literal is looked up as a key by `get`. When the result
+//     exists, the key was found and the error is not attached (e.g. `@type` on `{"@type":"x"}`).
+func withPathError(res Context, path string) Context {
+	if res.err != nil || !strings.ContainsAny(path, "(@![{") {
+		return res
+	}
+	_, err := compilePath(path)
+	if err == nil {
+		return res
+	}
+	var pathErr *PathError
+	if res.Exists() && errors.As(err, &pathErr) &&
+		(pathErr.Reason == "unknown transformer" || pathErr.Reason == "invalid literal") {
+		return res
+	}
+	res.err = err
+	return res
+}
+
+// newSyntaxError builds the `*SyntaxError` for invalid JSON data by locating the failure with
+// `diagnoseJSON`, and computing its line, column and excerpt.
+//
+// Parameters:
+//   - `data`: The invalid JSON data.
+//
+// Returns:
+//   - A `*SyntaxError` describing the first syntax error in the data.
+func newSyntaxError(data []byte) *SyntaxError {
+	offset, expected := diagnoseJSON(data)
+	return syntaxErrorAt(data, offset, expected)
+}
+
+// syntaxErrorAt builds the `*SyntaxError` for a syntax error at a known offset of the data, and
+// computes its line, column and excerpt.
+//
+// Parameters:
+//   - `data`: The invalid JSON data.
+//   - `offset`: The byte offset of the error.
+//   - `expected`: A description of the expected token.
+//
+// Returns:
+//   - A `*SyntaxError` describing the syntax error.
+func syntaxErrorAt(data []byte, offset int, expected string) *SyntaxError {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	e := &SyntaxError{Offset: offset, Line: 1, Expected: expected, Found: "end of input"}
+	if offset < len(data) {
+		r, _ := utf8.DecodeRune(data[offset:])
+		e.Found = strconv.QuoteRune(r)
+	}
+	lineStart := 0
+	for i := 0; i < offset; i++ {
+		if data[i] == '\n' {
+			e.Line++
+			lineStart = i + 1
+		}
+	}
+	e.Column = utf8.RuneCount(data[lineStart:offset]) + 1
+	lineEnd := offset
+	for lineEnd < len(data) && data[lineEnd] != '\n' && data[lineEnd] != '\r' {
+		lineEnd++
+	}
+	const radius = 20
+	start, end := lineStart, lineEnd
+	if offset-start > radius {
+		start = offset - radius
+		for start < offset && !utf8.RuneStart(data[start]) {
+			start++
+		}
+	}
+	if end-offset > radius {
+		end = offset + radius
+		for end > offset && end < len(data) && !utf8.RuneStart(data[end]) {
+			end--
+		}
+	}
+	e.Excerpt = string(data[start:end])
+	return e
+}
+
+// diagnoseJSON locates the first syntax error in JSON data that `verifyJSON` rejected.
+//
+// The functions `diagnoseValue`, `diagnoseObject` and `diagnoseArray` follow the same grammar as
+// `verifyAny`, `verifyObject` and `verifyArray`, and reuse `verifyString` and `verifyNumeric` for
+// the scalar values, but they also track the token that is expected at each step.
+//
+// Parameters:
+//   - `data`: The JSON data.
+//
+// Returns:
+//   - The byte offset of the error.
+//   - A description of the expected token.
+func diagnoseJSON(data []byte) (offset int, expected string) {
+	i, expected, ok := diagnoseValue(data, 0)
+	if !ok {
+		return i, expected
+	}
+	i = skipJSONSpace(data, i)
+	return i, "end of input"
+}
+
+// diagnoseValue checks the JSON value that starts at or after `i` (see `diagnoseJSON`).
+//
+// Returns:
+//   - The offset just past the value, or the offset of the error.
+//   - A description of the expected token when the value is invalid.
+//   - A boolean indicating whether the value is valid.
+func diagnoseValue(data []byte, i int) (int, string, bool) {
+	i = skipJSONSpace(data, i)
+	if i == len(data) {
+		return i, "value", false
+	}
+	switch data[i] {
+	case '{':
+		return diagnoseObject(data, i+1)
+	case '[':
+		return diagnoseArray(data, i+1)
+	case '"':
+		return diagnoseString(data, i)
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		j, ok := verifyNumeric(data, i+1)
+		if !ok {
+			return j, "digit", false
+		}
+		return j, "", true
+	case 't':
+		return diagnoseLiteral(data, i, "true")
+	case 'f':
+		return diagnoseLiteral(data, i, "false")
+	case 'n':
+		return diagnoseLiteral(data, i, "null")
+	}
+	return i, "value", false
+}
+
+// diagnoseObject checks the members of the JSON object whose '{' precedes `i` (see `diagnoseJSON`).
+func diagnoseObject(data []byte, i int) (int, string, bool) {
+	i = skipJSONSpace(data, i)
+	if i < len(data) && data[i] == '}' {
+		return i + 1, "", true
+	}
+	if i == len(data) || data[i] != '"' {
+		return i, "object key or '}'", false
+	}
+	for {
+		var expected string
+		var ok bool
+		if i, expected, ok = diagnoseString(data, i); !ok {
+			return i, expected, false
+		}
+		i = skipJSONSpace(data, i)
+		if i == len(data) || data[i] != ':' {
+			return i, "':'", false
+		}
+		if i, expected, ok = diagnoseValue(data, i+1); !ok {
+			return i, expected, false
+		}
+		i = skipJSONSpace(data, i)
+		if i == len(data) || (data[i] != ',' && data[i] != '}') {
+			return i, "',' or '}'", false
+		}
+		if data[i] == '}' {
+			return i + 1, "", true
+		}
+		i = skipJSONSpace(data, i+1)
+		if i == len(data) || data[i] != '"' {
+			return i, "object key", false
+		}
+	}
+}
+
+// diagnoseArray checks the elements of the JSON array whose '[' precedes `i` (see `diagnoseJSON`).
+func diagnoseArray(data []byte, i int) (int, string, bool) {
+	i = skipJSONSpace(data, i)
+	if i < len(data) && data[i] == ']' {
+		return i + 1, "", true
+	}
+	for {
+		var expected string
+		var ok bool
+		if i, expected, ok = diagnoseValue(data, i); !ok {
+			return i, expected, false
+		}
+		i = skipJSONSpace(data, i)
+		if i == len(data) || (data[i] != ',' && data[i] != ']') {
+			return i, "',' or ']'", false
+		}
+		if data[i] == ']' {
+			return i + 1, "", true
+		}
+		i++
+	}
+}
+
+// diagnoseString checks the JSON string whose opening quote is at `i` with `verifyString`, and
+// describes the expected token when it is invalid (see `diagnoseJSON`).
+func diagnoseString(data []byte, i int) (int, string, bool) {
+	j, ok := verifyString(data, i+1)
+	if ok {
+		return j, "", true
+	}
+	if j >= len(data) {
+		return len(data), "closing '\"'", false
+	}
+	// find the start of the escape sequence, if the failure is inside one.
+	for k := j - 1; k > i && k >= j-5; k-- {
+		if data[k] != '\\' {
+			continue
+		}
+		n := 0
+		for b := k - 1; b > i && data[b] == '\\'; b-- {
+			n++
+		}
+		if n%2 == 1 {
+			continue // the backslash is itself escaped
+		}
+		if k == j-1 {
+			return j, "escape character", false
+		}
+		if data[k+1] == 'u' {
+			return j, "hexadecimal digit", false
+		}
+		break
+	}
+	return j, "escaped control character", false
+}
+
+// diagnoseLiteral checks that the literal `want` (true, false or null) starts at `i`, and returns
+// the offset of the first mismatching character otherwise (see `diagnoseJSON`).
+func diagnoseLiteral(data []byte, i int, want string) (int, string, bool) {
+	for k := 0; k < len(want); k++ {
+		if i+k == len(data) || data[i+k] != want[k] {
+			return i + k, strconv.Quote(want), false
+		}
+	}
+	return i + len(want), "", true
+}
+
+// skipJSONSpace returns the offset of the first character at or after `i` that is not JSON
+// whitespace (space, tab, newline or carriage return).
+func skipJSONSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+			continue
+		}
+		break
+	}
+	return i
+}
+
+// next reads the next element of the array stream (see `ArrayStream.Next`), descending to the
+// array on the first call.
+func (s *ArrayStream) next() (Context, error) {
+	if !s.started {
+		if err := s.descend(); err != nil {
+			return Context{}, err
+		}
+		s.started = true
+	}
+	if err := s.skipSpace(); err != nil {
+		return Context{}, err
+	}
+	if s.count > 0 {
+		// the previous element must be followed by a comma or the closing bracket.
+		if len(s.buf) == 0 || (s.buf[0] != ',' && s.buf[0] != ']') {
+			return Context{}, s.syntaxError(0, "',' or ']'")
+		}
+		if s.buf[0] == ']' {
+			s.discard(1)
+			return Context{}, io.EOF
+		}
+		s.discard(1)
+		if err := s.skipSpace(); err != nil {
+			return Context{}, err
+		}
+	} else if len(s.buf) > 0 && s.buf[0] == ']' {
+		s.discard(1)
+		return Context{}, io.EOF
+	}
+	n, err := s.value()
+	if err != nil {
+		return Context{}, err
+	}
+	ctx := Parse(string(s.buf[:n]))
+	ctx.index = s.offset
+	s.discard(n)
+	s.count++
+	return ctx, nil
+}
+
+// descend advances the array stream to the first element of the array located at the path of the
+// stream, skipping the values that precede it one at a time.
+func (s *ArrayStream) descend() error {
+	var components []pathComponent
+	if len(s.path) > 0 {
+		var ok bool
+		if components, ok = splitPathComponents(s.path); !ok {
+			return ErrUnsupportedPath
+		}
+	}
+	for _, component := range components {
+		if err := s.skipSpace(); err != nil {
+			return err
+		}
+		if len(s.buf) == 0 {
+			return s.syntaxError(0, "value")
+		}
+		var found bool
+		var err error
+		switch s.buf[0] {
+		case '{':
+			found, err = s.seekMember(component.key)
+		case '[':
+			if component.index >= 0 {
+				found, err = s.seekElement(component.index)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("fj: path %q: %w", s.path, ErrNotExist)
+		}
+	}
+	if err := s.skipSpace(); err != nil {
+		return err
+	}
+	if len(s.buf) == 0 {
+		return s.syntaxError(0, "'['")
+	}
+	if s.buf[0] != '[' {
+		if strings.IndexByte(`{"-0123456789tfn`, s.buf[0]) < 0 {
+			return s.syntaxError(0, "'['")
+		}
+		return ErrNotArray
+	}
+	s.discard(1)
+	return nil
+}
+
+// seekMember advances the array stream, positioned on the opening brace of an object, to the value
+// of the member with the given key.
+//
+// Returns:
+//   - A boolean indicating whether the member was found.
+//   - A `*SyntaxError` if the object is malformed, or an error returned by the reader.
+func (s *ArrayStream) seekMember(key string) (bool, error) {
+	s.discard(1)
+	for {
+		if err := s.skipSpace(); err != nil {
+			return false, err
+		}
+		if len(s.buf) > 0 && s.buf[0] == '}' {
+			return false, nil
+		}
+		if len(s.buf) == 0 || s.buf[0] != '"' {
+			return false, s.syntaxError(0, "object key")
+		}
+		n, err := s.value()
+		if err != nil {
+			return false, err
+		}
+		name := Parse(string(s.buf[:n])).String()
+		s.discard(n)
+		if err := s.skipSpace(); err != nil {
+			return false, err
+		}
+		if len(s.buf) == 0 || s.buf[0] != ':' {
+			return false, s.syntaxError(0, "':'")
+		}
+		s.discard(1)
+		if name == key {
+			return true, nil
+		}
+		if err := s.skipValue(); err != nil {
+			return false, err
+		}
+		if len(s.buf) == 0 || (s.buf[0] != ',' && s.buf[0] != '}') {
+			return false, s.syntaxError(0, "',' or '}'")
+		}
+		if s.buf[0] == '}' {
+			return false, nil
+		}
+		s.discard(1)
+	}
+}
+
+// seekElement advances the array stream, positioned on the opening bracket of an array, to the
+// element at the given index.
+//
+// Returns:
+//   - A boolean indicating whether the element was found.
+//   - A `*SyntaxError` if the array is malformed, or an error returned by the reader.
+func (s *ArrayStream) seekElement(index int) (bool, error) {
+	s.discard(1)
+	for i := 0; ; i++ {
+		if err := s.skipSpace(); err != nil {
+			return false, err
+		}
+		if i == 0 && len(s.buf) > 0 && s.buf[0] == ']' {
+			return false, nil
+		}
+		if i == index {
+			return true, nil
+		}
+		if err := s.skipValue(); err != nil {
+			return false, err
+		}
+		if len(s.buf) == 0 || (s.buf[0] != ',' && s.buf[0] != ']') {
+			return false, s.syntaxError(0, "',' or ']'")
+		}
+		if s.buf[0] == ']' {
+			return false, nil
+		}
+		s.discard(1)
+	}
+}
+
+// skipValue discards the value at the current position of the array stream, and the whitespace
+// that follows it.
+func (s *ArrayStream) skipValue() error {
+	if err := s.skipSpace(); err != nil {
+		return err
+	}
+	n, err := s.value()
+	if err != nil {
+		return err
+	}
+	s.discard(n)
+	return s.skipSpace()
+}
+
+// value returns the length of the complete JSON value at the start of the buffer of the array
+// stream, reading more input until the value is complete.
+//
+// The end of the value is found by a structural scan that tracks the nesting depth and the string
+// state, and resumes where it stopped after each read, so that a large value read in small chunks
+// is scanned once. The complete value is then validated with `verifyAny`.
+func (s *ArrayStream) value() (int, error) {
+	end, depth := -1, 0
+	var inString, escaped bool
+	for i := 0; ; {
+		for ; i < len(s.buf) && end < 0; i++ {
+			c := s.buf[i]
+			if inString {
+				if escaped {
+					escaped = false
+				} else if c == '\\' {
+					escaped = true
+				} else if c == '"' {
+					inString = false
+					if depth == 0 {
+						end = i + 1
+					}
+				}
+				continue
+			}
+			switch c {
+			case '"':
+				inString = true
+			case '{', '[':
+				depth++
+			case '}', ']':
+				if depth--; depth <= 0 {
+					end = i + 1
+				}
+			case ' ', '\t', '\n', '\r', ',', ':':
+				// a number or a literal ends at the first byte that cannot be part of it.
+				if depth == 0 && i > 0 {
+					end = i
+				}
+			}
+		}
+		if end >= 0 || s.eof {
+			n, ok := verifyAny(s.buf, 0)
+			if ok {
+				return n, nil
+			}
+			offset, expected, _ := diagnoseValue(s.buf, 0)
+			if len(expected) == 0 {
+				expected = "value"
+			}
+			return 0, s.syntaxError(offset, expected)
+		}
+		if err := s.fill(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// skipSpace discards the whitespace at the current position of the array stream, reading more
+// input as needed. After it returns without an error, the buffer is either empty at EOF or starts
+// with a non-whitespace character.
+func (s *ArrayStream) skipSpace() error {
+	for {
+		i := skipJSONSpace(s.buf, 0)
+		s.discard(i)
+		if len(s.buf) > 0 || s.eof {
+			return nil
+		}
+		if err := s.fill(); err != nil {
+			return err
+		}
+	}
+}
+
+// fill reads more input into the buffer of the array stream, moving the pending input to a new
+// buffer when the current one is full. It sets the eof flag once the reader is exhausted.
+func (s *ArrayStream) fill() error {
+	if s.eof {
+		return nil
+	}
+	if len(s.buf) == cap(s.buf) {
+		// the buffer only holds the pending input, so the new buffer is sized from its length.
+		buf := make([]byte, len(s.buf), max(2*len(s.buf), 4096))
+		copy(buf, s.buf)
+		s.buf = buf
+	}
+	for {
+		n, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+n]
+		if err == io.EOF {
+			s.eof = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+	}
+}
+
+// discard drops the first n bytes of the buffer of the array stream, keeping track of the offset,
+// line and column of the new start of the buffer.
+func (s *ArrayStream) discard(n int) {
+	if n == 0 {
+		return
+	}
+	consumed := s.buf[:n]
+	if i := bytes.LastIndexByte(consumed, '\n'); i >= 0 {
+		s.line += bytes.Count(consumed, []byte{'\n'})
+		s.column = utf8.RuneCount(consumed[i+1:])
+	} else {
+		s.column += utf8.RuneCount(consumed)
+	}
+	s.offset += n
+	s.buf = s.buf[n:]
+}
+
+// syntaxError builds the `*SyntaxError` for a syntax error at an offset of the buffer of the array
+// stream, with its offset, line and column counted from the start of the stream.
+func (s *ArrayStream) syntaxError(offset int, expected string) error {
+	e := syntaxErrorAt(s.buf, offset, expected)
+	if e.Line == 1 {
+		e.Column += s.column
+	}
+	e.Line += s.line
+	e.Offset += s.offset
+	return e
+}
+
+// locateComponents traces the position of a Result within the original JSON back to the root, and
+// returns the unescaped keys and array indexes that lead to it. It is used by `Context.Path` and
+// `Context.Pointer`.
+//
+// Parameters:
+//   - `ctx`: The Result, which must reference a single value stored in `json`.
+//   - `json`: The original JSON used when calling `Get`.
+//
+// Returns:
+//   - components: The object keys and array indexes from the root to the Result. It is empty when
+//     the Result is the root value.
+//   - ok: A boolean indicating whether the components could be determined.
+func locateComponents(ctx Context, json string) (components []string, ok bool) {
+	i := ctx.index - 1
+	// Ensure the index is within bounds of the original JSON
+	if ctx.index+len(ctx.unprocessed) > len(json) {
+		// JSON cannot safely contain the Result.
+		goto fail
+	}
+	// Ensure that the unprocessed part matches the expected JSON structure
+	if !strings.HasPrefix(json[ctx.index:], ctx.unprocessed) {
+		// Result is not at the expected index in the JSON.
+		goto fail
+	}
+	// Traverse the JSON from the result's index to extract the path
+	for ; i >= 0; i-- {
+		if json[i] <= ' ' {
+			continue
+		}
+		if json[i] == ':' {
+			for ; i >= 0; i-- {
+				if json[i] != '"' {
+					continue
+				}
+				break
+			}
+			raw := reverseSquash(json[:i+1])
+			i = i - len(raw)
+			components = append(components, raw)
+			// Key obtained, now process the next component
+			raw = reverseSquash(json[:i+1])
+			i = i - len(raw)
+			i++ // Move index for next loop step
+		} else if json[i] == '{' {
+			// Encountered an open object, this is likely not a valid result
+			goto fail
+		} else if json[i] == ',' || json[i] == '[' {
+			// Inside an array, count the position of the element
+			var arrayIdx int
+			if json[i] == ',' {
+				arrayIdx++
+				i--
+			}
+			for ; i >= 0; i-- {
+				if json[i] == ':' {
+					// Unexpected colon indicates an object key
+					goto fail
+				} else if json[i] == ',' {
+					arrayIdx++
+				} else if json[i] == '[' {
+					components = append(components, strconv.Itoa(arrayIdx))
+					break
+				} else if json[i] == ']' || json[i] == '}' || json[i] == '"' {
+					raw := reverseSquash(json[:i+1])
+					i = i - len(raw) + 1
+				}
+			}
+		}
+	}
+	// Unescape each key, from the root to the result
+	for i, j := 0, len(components)-1; i < j; i, j = i+1, j-1 {
+		components[i], components[j] = components[j], components[i]
+	}
+	for i, component := range components {
+		rawComplexity := Parse(component)
+		if !rawComplexity.Exists() {
+			goto fail
+		}
+		components[i] = rawComplexity.String()
+	}
+	return components, true
+fail:
+	return nil, false
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens.
+//
+// Parameters:
+//   - `pointer`: The JSON Pointer to split, such as "/a~1b/0".
+//
+// Returns:
+//   - tokens: The unescaped reference tokens (e.g. ["a/b", "0"]). It is empty for the pointer "",
+//     which references the whole document.
+//   - err: A `*PathError` if the pointer does not start with '/', or contains a '~' that is not
+//     followed by '0' or '1'.
+func parsePointer(pointer string) (tokens []string, err error) {
+	if len(pointer) == 0 {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, newPathError(pointer, 0, "pointer must start with '/'")
+	}
+	var token []byte
+	for i := 1; i <= len(pointer); i++ {
+		if i == len(pointer) || pointer[i] == '/' {
+			tokens = append(tokens, string(token))
+			token = token[:0]
+			continue
+		}
+		if pointer[i] == '~' {
+			if i+1 == len(pointer) || (pointer[i+1] != '0' && pointer[i+1] != '1') {
+				return nil, newPathError(pointer, i, "invalid pointer escape")
+			}
+			i++
+			if pointer[i] == '0' {
+				token = append(token, '~')
+			} else {
+				token = append(token, '/')
+			}
+			continue
+		}
+		token = append(token, pointer[i])
+	}
+	return tokens, nil
+}
+
+// escapePointerToken escapes a key for use as an RFC 6901 JSON Pointer reference token, replacing
+// '~' with '~0' and '/' with '~1'.
+//
+// Example Usage:
+//
+//	escapePointerToken("a/b~c") // "a~1b~0c"
+func escapePointerToken(key string) string {
+	if strings.IndexByte(key, '~') < 0 && strings.IndexByte(key, '/') < 0 {
+		return key
+	}
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// getPointer walks a JSON document along the unescaped reference tokens of a JSON Pointer (see
+// `GetPointer`), matching object keys literally and array elements by their decimal index.
+//
+// Parameters:
+//   - `json`: The JSON document.
+//   - `tokens`: The reference tokens, as returned by `parsePointer`.
+//
+// Returns:
+//   - The referenced value, with its index in `json`, or an empty `Context` if it does not exist.
+func getPointer(json string, tokens []string) Context {
+	ctx := Parse(json)
+	for _, token := range tokens {
+		var next Context
+		switch {
+		case ctx.IsObject():
+			ctx.Foreach(func(key, value Context) bool {
+				if key.String() == token {
+					next = value
+					return false
+				}
+				return true
+			})
+		case ctx.IsArray():
+			index, ok := parsePointerIndex(token)
+			if !ok {
+				return Context{}
+			}
+			i := 0
+			ctx.Foreach(func(_, value Context) bool {
+				if i == index {
+					next = value
+					return false
+				}
+				i++
+				return true
+			})
+		}
+		if !next.Exists() {
+			return Context{}
+		}
+		ctx = next
+	}
+	return ctx
+}
+
+// parsePointerIndex parses a JSON Pointer reference token as an array index, which is either "0" or
+// a decimal number without leading zeros.
+//
+// Returns:
+//   - The index, and a boolean indicating whether the token is a valid array index.
+func parsePointerIndex(token string) (int, bool) {
+	if len(token) == 0 || len(token) > 10 || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+	n, ok := parseUint64(token)
+	if !ok || n > math.MaxInt32 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+// parseJSONPath parses an RFC 9535 JSONPath expression (see `JSONPath`).
+//
+// Parameters:
+//   - `expr`: The JSONPath expression, which must start with the root identifier `$`.
+//
+// Returns:
+//   - The parsed query.
+//   - A `*PathError` describing the first syntax error in the expression, with its offset.
+func parseJSONPath(expr string) (*jsonPathQuery, error) {
+	p := &jsonPathParser{expr: expr}
+	if !p.consume("$") {
+		return nil, p.fail("expected '$'")
+	}
+	q, err := p.parseSegments(false)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.expr) {
+		return nil, p.fail("unexpected character")
+	}
+	return q, nil
+}
+
+// fail returns a `*PathError` at the current position of the JSONPath parser.
+func (p *jsonPathParser) fail(reason string) error {
+	return newPathError(p.expr, p.pos, reason)
+}
+
+// skipSpace skips the blank characters (space, tab, newline and carriage return) at the current
+// position of the JSONPath parser.
+func (p *jsonPathParser) skipSpace() {
+	for p.pos < len(p.expr) && strings.IndexByte(" \t\n\r", p.expr[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume advances the JSONPath parser past `token` if the expression continues with it.
+func (p *jsonPathParser) consume(token string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+// peek returns the character at the current position of the JSONPath parser, or 0 at the end.
+func (p *jsonPathParser) peek() byte {
+	if p.pos < len(p.expr) {
+		return p.expr[p.pos]
+	}
+	return 0
+}
+
+// parseSegments parses the segments that follow the root (`$`) or current node (`@`) identifier.
+// Blank characters are allowed between the segments.
+func (p *jsonPathParser) parseSegments(relative bool) (*jsonPathQuery, error) {
+	q := &jsonPathQuery{relative: relative}
+	for {
+		start := p.pos
+		p.skipSpace()
+		var segment jsonPathSegment
+		switch {
+		case p.consume(".."):
+			segment.descendant = true
+			if p.peek() == '[' {
+				selectors, err := p.parseBracketed()
+				if err != nil {
+					return nil, err
+				}
+				segment.selectors = selectors
+			} else {
+				selector, err := p.parseShorthand()
+				if err != nil {
+					return nil, err
+				}
+				segment.selectors = []jsonPathSelector{selector}
+			}
+		case p.consume("."):
+			selector, err := p.parseShorthand()
+			if err != nil {
+				return nil, err
+			}
+			segment.selectors = []jsonPathSelector{selector}
+		case p.peek() == '[':
+			selectors, err := p.parseBracketed()
+			if err != nil {
+				return nil, err
+			}
+			segment.selectors = selectors
+		default:
+			p.pos = start
+			return q, nil
+		}
+		q.segments = append(q.segments, segment)
+	}
+}
+
+// parseShorthand parses the wildcard (`*`) or member name that follows '.' or '..'.
+func (p *jsonPathParser) parseShorthand() (jsonPathSelector, error) {
+	if p.consume("*") {
+		return jsonPathSelector{kind: '*'}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.expr) {
+		c := p.expr[p.pos]
+		if c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(p.pos > start && c >= '0' && c <= '9') {
+			p.pos++
+			continue
+		}
+		break
+	}
+	if p.pos == start {
+		return jsonPathSelector{}, p.fail("expected member name or '*'")
+	}
+	return jsonPathSelector{kind: 'n', name: p.expr[start:p.pos]}, nil
+}
+
+// parseBracketed parses a bracketed, comma-separated list of selectors, such as `['a',0,1:3]`.
+func (p *jsonPathParser) parseBracketed() ([]jsonPathSelector, error) {
+	p.pos++ // '['
+	var selectors []jsonPathSelector
+	for {
+		p.skipSpace()
+		selector, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+		p.skipSpace()
+		if p.consume("]") {
+			return selectors, nil
+		}
+		if !p.consume(",") {
+			return nil, p.fail("expected ',' or ']'")
+		}
+	}
+}
+
+// parseSelector parses a single selector within brackets: a name, a wildcard, an index, a slice or
+// a filter.
+func (p *jsonPathParser) parseSelector() (jsonPathSelector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		name, err := p.parseString()
+		if err != nil {
+			return jsonPathSelector{}, err
+		}
+		return jsonPathSelector{kind: 'n', name: name}, nil
+	case c == '*':
+		p.pos++
+		return jsonPathSelector{kind: '*'}, nil
+	case c == '?':
+		p.pos++
+		p.skipSpace()
+		expr, err := p.parseOr()
+		if err != nil {
+			return jsonPathSelector{}, err
+		}
+		return jsonPathSelector{kind: '?', filter: expr}, nil
+	case c == '-' || c == ':' || (c >= '0' && c <= '9'):
+		var bounds [3]*int
+		for k := 0; k < 3; k++ {
+			p.skipSpace()
+			if c := p.peek(); c == '-' || (c >= '0' && c <= '9') {
+				n, err := p.parseInt()
+				if err != nil {
+					return jsonPathSelector{}, err
+				}
+				bounds[k] = &n
+				p.skipSpace()
+			}
+			if k == 0 && p.peek() != ':' {
+				if bounds[0] == nil {
+					return jsonPathSelector{}, p.fail("expected selector")
+				}
+				return jsonPathSelector{kind: 'i', index: *bounds[0]}, nil
+			}
+			if k == 2 || !p.consume(":") {
+				break
+			}
+		}
+		return jsonPathSelector{kind: ':', slice: bounds}, nil
+	}
+	return jsonPathSelector{}, p.fail("expected selector")
+}
+
+// parseInt parses an integer without leading zeros, such as `0`, `12` or `-3`.
+func (p *jsonPathParser) parseInt() (int, error) {
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for p.pos < len(p.expr) && p.expr[p.pos] >= '0' && p.expr[p.pos] <= '9' {
+		p.pos++
+	}
+	text := p.expr[start:p.pos]
+	if p.pos == digits || (p.expr[digits] == '0' && (p.pos-digits > 1 || digits > start)) {
+		p.pos = start
+		return 0, p.fail("invalid integer")
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil {
+		p.pos = start
+		return 0, p.fail("invalid integer")
+	}
+	return n, nil
+}
+
+// parseString parses a single-quoted or double-quoted string literal, with the JSON escape sequences
+// and the `\'` escape sequence.
+func (p *jsonPathParser) parseString() (string, error) {
+	quote := p.expr[p.pos]
+	p.pos++
+	var str []byte
+	for p.pos < len(p.expr) {
+		c := p.expr[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return string(str), nil
+		case c < ' ':
+			return "", p.fail("invalid character in string")
+		case c != '\\':
+			str = append(str, c)
+			p.pos++
+			continue
+		}
+		p.pos++
+		switch e := p.peek(); e {
+		case 'b':
+			str = append(str, '\b')
+		case 'f':
+			str = append(str, '\f')
+		case 'n':
+			str = append(str, '\n')
+		case 'r':
+			str = append(str, '\r')
+		case 't':
+			str = append(str, '\t')
+		case '/', '\\':
+			str = append(str, e)
+		case '\'', '"':
+			if e != quote {
+				return "", p.fail("invalid escape sequence")
+			}
+			str = append(str, e)
+		case 'u':
+			r := parseHexRune(p.expr[p.pos+1:])
+			if r < 0 {
+				return "", p.fail("invalid escape sequence")
+			}
+			p.pos += 4
+			if utf16.IsSurrogate(r) {
+				if !strings.HasPrefix(p.expr[p.pos+1:], `\u`) {
+					return "", p.fail("invalid escape sequence")
+				}
+				r = utf16.DecodeRune(r, parseHexRune(p.expr[p.pos+3:]))
+				if r == unicode.ReplacementChar {
+					return "", p.fail("invalid escape sequence")
+				}
+				p.pos += 6
+			}
+			str = utf8.AppendRune(str, r)
+		default:
+			return "", p.fail("invalid escape sequence")
+		}
+		p.pos++
+	}
+	return "", p.fail("unterminated string")
+}
+
+// parseHexRune parses the four hexadecimal digits at the start of s, and returns -1 if they are
+// missing or invalid.
+func parseHexRune(s string) rune {
+	if len(s) < 4 {
+		return -1
+	}
+	n, err := strconv.ParseUint(s[:4], 16, 32)
+	if err != nil {
+		return -1
+	}
+	return rune(n)
+}
+
+// parseOr parses a logical OR expression of a filter: `a || b || ...`.
+func (p *jsonPathParser) parseOr() (*jsonPathExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("||") {
+			return left, nil
+		}
+		p.skipSpace()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &jsonPathExpr{op: "||", left: left, right: right}
+	}
+}
+
+// parseAnd parses a logical AND expression of a filter: `a && b && ...`.
+func (p *jsonPathParser) parseAnd() (*jsonPathExpr, error) {
+	left, err := p.parseBasic()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		p.skipSpace()
+		if !p.consume("&&") {
+			return left, nil
+		}
+		p.skipSpace()
+		right, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		left = &jsonPathExpr{op: "&&", left: left, right: right}
+	}
+}
+
+// parseBasic parses a parenthesized expression, an existence test or a comparison, optionally
+// negated with '!' (except for comparisons).
+func (p *jsonPathParser) parseBasic() (*jsonPathExpr, error) {
+	if p.consume("!") {
+		p.skipSpace()
+		if p.peek() != '(' && p.peek() != '@' && p.peek() != '$' {
+			return nil, p.fail("expected '(' or query after '!'")
+		}
+		expr, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		if expr.op != "exists" && expr.op != "(" {
+			return nil, p.fail("comparisons cannot be negated without parentheses")
+		}
+		return &jsonPathExpr{op: "!", left: expr}, nil
+	}
+	if p.consume("(") {
+		p.skipSpace()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.consume(")") {
+			return nil, p.fail("expected ')'")
+		}
+		return &jsonPathExpr{op: "(", left: expr}, nil
+	}
+	start := p.pos
+	left, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.consume(op) {
+			continue
+		}
+		if err := p.checkSingular(left, start); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		start = p.pos
+		right, err := p.parseComparable()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.checkSingular(right, start); err != nil {
+			return nil, err
+		}
+		return &jsonPathExpr{op: op, operands: [2]jsonPathValue{left, right}}, nil
+	}
+	if left.query == nil {
+		p.pos = start
+		return nil, p.fail("expected comparison or query")
+	}
+	return &jsonPathExpr{op: "exists", query: left.query}, nil
+}
+
+// checkSingular reports an error at `start` if the value is a query that may select more than one
+// node, since comparisons only accept singular queries (names and indexes, without descendants).
+func (p *jsonPathParser) checkSingular(v jsonPathValue, start int) error {
+	if v.query == nil {
+		return nil
+	}
+	for _, segment := range v.query.segments {
+		if segment.descendant || len(segment.selectors) != 1 ||
+			(segment.selectors[0].kind != 'n' && segment.selectors[0].kind != 'i') {
+			return newPathError(p.expr, start, "non-singular query in comparison")
+		}
+	}
+	return nil
+}
+
+// parseComparable parses a literal (number, string, true, false or null) or a query that starts
+// with `@` or `$`.
+func (p *jsonPathParser) parseComparable() (jsonPathValue, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		q, err := p.parseSegments(c == '@')
+		if err != nil {
+			return jsonPathValue{}, err
+		}
+		return jsonPathValue{query: q}, nil
+	case c == '\'' || c == '"':
+		str, err := p.parseString()
+		if err != nil {
+			return jsonPathValue{}, err
+		}
+		raw := string(appendJSON(nil, str))
+		return jsonPathValue{literal: Context{kind: String, strings: str, unprocessed: raw}}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.expr) && strings.IndexByte("-+.eE0123456789", p.expr[p.pos]) >= 0 {
+			p.pos++
+		}
+		if n, ok := verifyNumeric([]byte(p.expr[start:p.pos]), 1); !ok || n != p.pos-start {
+			p.pos = start
+			return jsonPathValue{}, p.fail("invalid number")
+		}
+		return jsonPathValue{literal: Parse(p.expr[start:p.pos])}, nil
+	}
+	for _, literal := range []string{"true", "false", "null"} {
+		if p.consume(literal) {
+			return jsonPathValue{literal: Parse(literal)}, nil
+		}
+	}
+	start := p.pos
+	for p.pos < len(p.expr) && (p.expr[p.pos] == '_' || unicode.IsLetter(rune(p.expr[p.pos]))) {
+		p.pos++
+	}
+	if p.pos > start && p.peek() == '(' {
+		p.pos = start
+		return jsonPathValue{}, p.fail("function extensions are not supported")
+	}
+	p.pos = start
+	return jsonPathValue{}, p.fail("expected literal or query")
+}
+
+// evaluate applies the JSONPath query to a start node, and returns the selected nodes in order.
+//
+// Parameters:
+//   - `root`: The root node of the document, for absolute queries.
+//   - `current`: The current node of a filter, for relative queries.
+func (q *jsonPathQuery) evaluate(root, current jsonPathNode) []jsonPathNode {
+	nodes := []jsonPathNode{root}
+	if q.relative {
+		nodes[0] = current
+	}
+	for _, segment := range q.segments {
+		var next []jsonPathNode
+		for _, node := range nodes {
+			if segment.descendant {
+				for _, descendant := range jsonPathDescendants(node, nil) {
+					next = segment.apply(next, descendant, root)
+				}
+			} else {
+				next = segment.apply(next, node, root)
+			}
+		}
+		nodes = next
+		if len(nodes) == 0 {
+			break
+		}
+	}
+	return nodes
+}
+
+// jsonPathDescendants appends the node and all of its descendants to `all`, visiting each node
+// before its children, and the elements of arrays in order.
+func jsonPathDescendants(node jsonPathNode, all []jsonPathNode) []jsonPathNode {
+	all = append(all, node)
+	for _, child := range jsonPathChildren(node) {
+		all = jsonPathDescendants(child, all)
+	}
+	return all
+}
+
+// jsonPathChildren returns the members of an object or the elements of an array, with their
+// normalized paths, or nil for any other value.
+func jsonPathChildren(node jsonPathNode) []jsonPathNode {
+	if !node.value.IsObject() && !node.value.IsArray() {
+		return nil
+	}
+	var children []jsonPathNode
+	i := 0
+	node.value.Foreach(func(key, value Context) bool {
+		if node.value.IsObject() {
+			children = append(children, jsonPathNode{value: value, path: node.path + normalizedName(key.String())})
+		} else {
+			children = append(children, jsonPathNode{value: value, path: node.path + "[" + strconv.Itoa(i) + "]"})
+		}
+		i++
+		return true
+	})
+	return children
+}
+
+// apply appends the nodes selected by the selectors of the segment from a node to `out`.
+func (segment jsonPathSegment) apply(out []jsonPathNode, node jsonPathNode, root jsonPathNode) []jsonPathNode {
+	if !node.value.IsObject() && !node.value.IsArray() {
+		return out
+	}
+	children := jsonPathChildren(node)
+	for _, selector := range segment.selectors {
+		switch selector.kind {
+		case 'n':
+			if !node.value.IsObject() {
+				continue
+			}
+			node.value.Foreach(func(key, value Context) bool {
+				if key.String() == selector.name {
+					out = append(out, jsonPathNode{value: value, path: node.path + normalizedName(selector.name)})
+					return false
+				}
+				return true
+			})
+		case '*':
+			out = append(out, children...)
+		case 'i':
+			index := selector.index
+			if index < 0 {
+				index += len(children)
+			}
+			if node.value.IsArray() && index >= 0 && index < len(children) {
+				out = append(out, children[index])
+			}
+		case ':':
+			if node.value.IsArray() {
+				for _, i := range sliceIndexes(len(children), selector.slice) {
+					out = append(out, children[i])
+				}
+			}
+		case '?':
+			for _, child := range children {
+				if selector.filter.test(root, child) {
+					out = append(out, child)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// sliceIndexes returns the indexes selected by a slice `[start:end:step]` of an array of length n,
+// following the semantics of RFC 9535 (and Python): negative bounds count from the end, the bounds
+// are clamped to the array, and a negative step selects the elements in reverse order.
+//
+// Parameters:
+//   - `n`: The length of the array.
+//   - `bounds`: The optional start, end and step of the slice. The step defaults to 1, and a step
+//     of 0 selects no elements.
+//
+// Example Usage:
+//
+//	two := 2
+//	sliceIndexes(6, [3]*int{nil, nil, &two}) // [0 2 4]
+func sliceIndexes(n int, bounds [3]*int) []int {
+	step := 1
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	if step == 0 {
+		return nil
+	}
+	normalize := func(i int) int {
+		if i < 0 {
+			return i + n
+		}
+		return i
+	}
+	var start, end int
+	if step > 0 {
+		start, end = 0, n
+	} else {
+		start, end = n-1, -n-1
+	}
+	if bounds[0] != nil {
+		start = normalize(*bounds[0])
+	}
+	if bounds[1] != nil {
+		end = normalize(*bounds[1])
+	}
+	var indexes []int
+	if step > 0 {
+		lower, upper := min(max(start, 0), n), min(max(end, 0), n)
+		for i := lower; i < upper; i += step {
+			indexes = append(indexes, i)
+		}
+	} else {
+		upper, lower := min(max(start, -1), n-1), min(max(end, -1), n-1)
+		for i := upper; i > lower; i += step {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// test evaluates the logical expression of a filter with `current` as the current node (`@`).
+// The operands of "&&" and "||" are evaluated from left to right, and only as far as needed.
+func (expr *jsonPathExpr) test(root, current jsonPathNode) bool {
+	switch expr.op {
+	case "||":
+		return expr.left.test(root, current) || expr.right.test(root, current)
+	case "&&":
+		return expr.left.test(root, current) && expr.right.test(root, current)
+	case "!":
+		return !expr.left.test(root, current)
+	case "(":
+		return expr.left.test(root, current)
+	case "exists":
+		return len(expr.query.evaluate(root, current)) > 0
+	}
+	left, leftOk := expr.operands[0].resolve(root, current)
+	right, rightOk := expr.operands[1].resolve(root, current)
+	equal := func() bool {
+		if !leftOk || !rightOk {
+			return leftOk == rightOk
+		}
+		return jsonEqual(left, right)
+	}
+	less := func(a, b Context) bool {
+		if !leftOk || !rightOk || a.kind != b.kind {
+			return false
+		}
+		switch a.kind {
+		case Number:
+			return a.numeric < b.numeric
+		case String:
+			return a.strings < b.strings
+		}
+		return false
+	}
+	switch expr.op {
+	case "==":
+		return equal()
+	case "!=":
+		return !equal()
+	case "<":
+		return less(left, right)
+	case "<=":
+		return less(left, right) || equal()
+	case ">":
+		return less(right, left)
+	case ">=":
+		return less(right, left) || equal()
+	}
+	return false
+}
+
+// resolve returns the value of a comparison operand, and a boolean indicating whether it exists
+// (a singular query that selects no node has no value).
+func (v jsonPathValue) resolve(root, current jsonPathNode) (Context, bool) {
+	if v.query == nil {
+		return v.literal, true
+	}
+	nodes := v.query.evaluate(root, current)
+	if len(nodes) != 1 {
+		return Context{}, false
+	}
+	return nodes[0].value, true
+}
+
+// normalizedName returns the normalized path segment of a member name, as defined by RFC 9535:
+// the name in single quotes within brackets, with quotes, backslashes and control characters escaped.
+//
+// Example Usage:
+//
+//	normalizedName("it's") // `['it\'s']`
+func normalizedName(name string) string {
+	var b strings.Builder
+	b.WriteString("['")
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '\'', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if c < ' ' {
+				fmt.Fprintf(&b, `\u%04x`, c)
+				continue
+			}
+			b.WriteByte(c)
+		}
+	}
+	b.WriteString("']")
+	return b.String()
+}
+
+// jsonEqual reports whether two JSON values are semantically equal: numbers are compared by value
+// (so `1` equals `1.0`), strings after unescaping, arrays element by element, and objects member by
+// member regardless of the order of their keys.
+//
+// Parameters:
+//   - `a`, `b`: The values to compare.
+//
+// Returns:
+//   - A boolean indicating whether the values are equal. Values that do not exist are only equal
+//     to each other.
+//
+// Example Usage:
+//
+//	jsonEqual(Parse(`{"a":[1,2],"b":"x"}`), Parse(`{"b":"x","a":[1.0,2e0]}`)) // true
+func jsonEqual(a, b Context) bool {
+	if a.kind != b.kind || a.Exists() != b.Exists() {
+		return false
+	}
+	switch a.kind {
+	case Number:
+		if a.numeric == b.numeric {
+			// numbers beyond the precision of float64 are also compared by their representation.
+			return math.Abs(a.numeric) < 1<<53 || a.unprocessed == b.unprocessed ||
+				strings.ContainsAny(a.unprocessed+b.unprocessed, ".eE")
+		}
+		return false
+	case String:
+		return a.strings == b.strings
+	case JSON:
+		if a.IsArray() != b.IsArray() {
+			return false
+		}
+		if a.IsArray() {
+			x, y := a.Array(), b.Array()
+			if len(x) != len(y) {
+				return false
+			}
+			for i := range x {
+				if !jsonEqual(x[i], y[i]) {
+					return false
+				}
+			}
+			return true
+		}
+		x, y := a.Map(), b.Map()
+		if len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// applyPatchOperation applies a single JSON Patch operation to a JSON document (see `ApplyPatch`).
+//
+// Parameters:
+//   - `doc`: The JSON document.
+//   - `operation`: The patch operation, an object with "op", "path", and "from" or "value" members.
+//
+// Returns:
+//   - The updated JSON document.
+//   - An error if the operation is malformed or cannot be applied.
+func applyPatchOperation(doc string, operation Context) (string, error) {
+	if !operation.IsObject() {
+		return doc, ErrInvalidPatch
+	}
+	op, path, from, value := operation.Get("op"), operation.Get("path"), operation.Get("from"), operation.Get("value")
+	if op.kind != String || path.kind != String {
+		return doc, ErrInvalidPatch
+	}
+	tokens, err := parsePointer(path.String())
+	if err != nil {
+		return doc, err
+	}
+	switch op.String() {
+	case "add", "replace", "test":
+		if !value.Exists() {
+			return doc, ErrInvalidPatch
+		}
+	case "move", "copy":
+		if from.kind != String {
+			return doc, ErrInvalidPatch
+		}
+		fromTokens, err := parsePointer(from.String())
+		if err != nil {
+			return doc, err
+		}
+		if value = getPointer(doc, fromTokens); !value.Exists() {
+			return doc, fmt.Errorf("fj: pointer %q: %w", from.String(), ErrNotExist)
+		}
+		if op.String() == "move" {
+			if from.String() == path.String() {
+				return doc, nil
+			}
+			if strings.HasPrefix(path.String(), from.String()+"/") {
+				return doc, ErrInvalidPatch // a value cannot be moved into one of its children
+			}
+			if doc, err = removeAtPointer(doc, from.String(), fromTokens); err != nil {
+				return doc, err
+			}
+		}
+	}
+	switch op.String() {
+	case "add", "move", "copy":
+		return addAtPointer(doc, path.String(), tokens, value.unprocessed)
+	case "remove":
+		return removeAtPointer(doc, path.String(), tokens)
+	case "replace":
+		target := getPointer(doc, tokens)
+		if !target.Exists() {
+			return doc, fmt.Errorf("fj: pointer %q: %w", path.String(), ErrNotExist)
+		}
+		return doc[:target.index] + value.unprocessed + doc[target.index+len(target.unprocessed):], nil
+	case "test":
+		if !jsonEqual(getPointer(doc, tokens), value) {
+			return doc, ErrTestFailed
+		}
+		return doc, nil
+	}
+	return doc, ErrInvalidPatch
+}
+
+// addAtPointer adds a raw JSON value at the location referenced by a JSON Pointer, following the
+// "add" operation of JSON Patch: an existing object member is replaced, a new member is appended
+// to its object, and a value added to an array is inserted before the element at the index, or
+// appended for the "-" token.
+//
+// Parameters:
+//   - `doc`: The JSON document.
+//   - `pointer`: The JSON Pointer, for error messages.
+//   - `tokens`: The reference tokens of the pointer.
+//   - `raw`: The raw JSON value to add.
+//
+// Returns:
+//   - The updated JSON document.
+//   - An error wrapping `ErrNotExist` if the parent of the location does not exist, or if the array
+//     index is out of range.
+func addAtPointer(doc, pointer string, tokens []string, raw string) (string, error) {
+	if len(tokens) == 0 {
+		return raw, nil
+	}
+	parent := getPointer(doc, tokens[:len(tokens)-1])
+	token := tokens[len(tokens)-1]
+	var count, last int
+	var target Context
+	last = -1
+	index, isIndex := parsePointerIndex(token)
+	parent.Foreach(func(key, value Context) bool {
+		if (parent.IsObject() && key.String() == token) || (parent.IsArray() && isIndex && count == index) {
+			target = value
+			return false
+		}
+		last = value.index + len(value.unprocessed)
+		count++
+		return true
+	})
+	switch {
+	case parent.IsObject() && target.Exists():
+		return doc[:target.index] + raw + doc[target.index+len(target.unprocessed):], nil
+	case parent.IsArray() && target.Exists():
+		return doc[:target.index] + raw + "," + doc[target.index:], nil
+	case parent.IsObject() || (parent.IsArray() && (token == "-" || (isIndex && index == count))):
+		var member []byte
+		if last >= 0 {
+			member = append(member, ',')
+		} else {
+			last = parent.index + 1
+		}
+		if parent.IsObject() {
+			member = appendJSON(member, token)
+			member = append(member, ':')
+		}
+		member = append(member, raw...)
+		return doc[:last] + string(member) + doc[last:], nil
+	}
+	return doc, fmt.Errorf("fj: pointer %q: %w", pointer, ErrNotExist)
+}
+
+// removeAtPointer removes the object member or array element referenced by a JSON Pointer, together
+// with its separating comma.
+//
+// Returns:
+//   - The updated JSON document.
+//   - An error wrapping `ErrNotExist` if the value does not exist, or `ErrUnaddressablePath` for the
+//     root value.
+func removeAtPointer(doc, pointer string, tokens []string) (string, error) {
+	if len(tokens) == 0 {
+		return doc, ErrUnaddressablePath
+	}
+	target := getPointer(doc, tokens)
+	if !target.Exists() {
+		return doc, fmt.Errorf("fj: pointer %q: %w", pointer, ErrNotExist)
+	}
+	return deleteAtContext(doc, target)
+}
+
+// mergePatch applies an RFC 7396 merge patch to a raw JSON value (see `MergePatch`).
+//
+// Parameters:
+//   - `target`: The raw JSON value to patch, which may be empty when the member does not exist yet.
+//   - `patch`: The merge patch.
+//
+// Returns:
+//   - The patched raw JSON value.
+func mergePatch(target string, patch Context) string {
+	if !patch.IsObject() {
+		return patch.unprocessed
+	}
+	if !Parse(target).IsObject() {
+		target = "{}"
+	}
+	patch.Foreach(func(key, value Context) bool {
+		obj := Parse(target)
+		var member Context
+		obj.Foreach(func(k, v Context) bool {
+			if k.String() == key.String() {
+				member = v
+				return false
+			}
+			return true
+		})
+		switch {
+		case value.kind == Null && member.Exists():
+			target, _ = deleteAtContext(target, member)
+		case value.kind == Null:
+		case member.Exists():
+			merged := mergePatch(member.unprocessed, value)
+			target = target[:member.index] + merged + target[member.index+len(member.unprocessed):]
+		default:
+			target = insertMember(target, obj, key.String(), mergePatch("", value))
+		}
+		return true
+	})
+	return target
+}
+
+// insertMember appends a member to an object, after its last member.
+//
+// Parameters:
+//   - `json`: The JSON document containing the object.
+//   - `obj`: The object, with its index in `json`.
+//   - `key`: The key of the new member.
+//   - `raw`: The raw JSON value of the new member.
+//
+// Returns:
+//   - The updated JSON document.
+//
+// Example Usage:
+//
+//	json := `{"a": 1}`
+//	insertMember(json, Parse(json), "b", "2") // {"a": 1,"b":2}
+func insertMember(json string, obj Context, key, raw string) string {
+	last := -1
+	obj.Foreach(func(_, value Context) bool {
+		last = value.index + len(value.unprocessed)
+		return true
+	})
+	var member []byte
+	if last >= 0 {
+		member = append(member, ',')
+	} else {
+		last = obj.index + 1
+	}
+	member = appendJSON(member, key)
+	member = append(member, ':')
+	member = append(member, raw...)
+	return json[:last] + string(member) + json[last:]
+}
+
+// createMergePatch creates the RFC 7396 merge patch that transforms a value into another (see
+// `CreateMergePatch`).
+func createMergePatch(original, modified Context) string {
+	if !original.IsObject() || !modified.IsObject() {
+		return modified.unprocessed
+	}
+	values := modified.Map()
+	patch := []byte{'{'}
+	add := func(key, raw string) {
+		if len(patch) > 1 {
+			patch = append(patch, ',')
+		}
+		patch = appendJSON(patch, key)
+		patch = append(patch, ':')
+		patch = append(patch, raw...)
+	}
+	seen := make(map[string]bool)
+	original.Foreach(func(key, value Context) bool {
+		name := key.String()
+		if seen[name] {
+			return true
+		}
+		seen[name] = true
+		other, ok := values[name]
+		if !ok {
+			add(name, "null")
+		} else if !jsonEqual(value, other) {
+			add(name, createMergePatch(value, other))
+		}
+		return true
+	})
+	modified.Foreach(func(key, value Context) bool {
+		if name := key.String(); !seen[name] {
+			seen[name] = true
+			add(name, value.unprocessed)
+		}
+		return true
+	})
+	return string(append(patch, '}'))
+}
+
+// compare compares two values of the documents of a diff, and records their differences.
+//
+// Parameters:
+//   - `path`: The fj path of the values, or "" for the root values.
+//   - `pattern`: The path of the values where array indexes are replaced by "#", which is matched
+//     against the keys of `DiffOptions.ArrayKeys`.
+//   - `x`, `y`: The values in the first and second document; either one may not exist.
+func (d *differ) compare(path, pattern string, x, y Context) {
+	switch {
+	case !x.Exists() && !y.Exists():
+		return
+	case !x.Exists():
+		d.report(path, Added, x, y)
+		return
+	case !y.Exists():
+		d.report(path, Removed, x, y)
+		return
+	case diffCategory(x) != diffCategory(y):
+		d.report(path, TypeChanged, x, y)
+		return
+	}
+	switch {
+	case x.IsObject():
+		d.compareObjects(path, pattern, x, y)
+	case x.IsArray():
+		d.compareArrays(path, pattern, x, y)
+	case x.kind == Number:
+		if (d.opts.Tolerance > 0 && math.Abs(x.numeric-y.numeric) > d.opts.Tolerance) ||
+			(d.opts.Tolerance <= 0 && !jsonEqual(x, y)) {
+			d.report(path, Changed, x, y)
+		}
+	case x.kind != y.kind || (x.kind == String && x.strings != y.strings):
+		d.report(path, Changed, x, y)
+	}
+}
+
+// compareObjects compares the members of two objects by key (see `differ.compare`).
+func (d *differ) compareObjects(path, pattern string, x, y Context) {
+	xKeys, xValues := diffMembers(x)
+	yKeys, yValues := diffMembers(y)
+	yIndex := make(map[string]int, len(yKeys))
+	for i, key := range yKeys {
+		yIndex[key] = i
+	}
+	xIndex := make(map[string]int, len(xKeys))
+	for i, key := range xKeys {
+		xIndex[key] = i
+	}
+	if !d.opts.IgnoreKeyOrder {
+		// the keys that exist in both objects must appear in the same relative order. A reordered
+		// object is reported as a whole, and its members are not compared one by one.
+		last := -1
+		for _, key := range xKeys {
+			if j, ok := yIndex[key]; ok {
+				if j < last {
+					d.report(path, Changed, x, y)
+					return
+				}
+				last = j
+			}
+		}
+	}
+	for i, key := range xKeys {
+		var other Context
+		if j, ok := yIndex[key]; ok {
+			other = yValues[j]
+		}
+		component := escapeUnsafeChars(key)
+		d.compare(joinPath(path, component), joinPath(pattern, component), xValues[i], other)
+	}
+	for j, key := range yKeys {
+		if _, ok := xIndex[key]; !ok {
+			component := escapeUnsafeChars(key)
+			d.compare(joinPath(path, component), joinPath(pattern, component), Context{}, yValues[j])
+		}
+	}
+}
+
+// compareArrays compares the elements of two arrays, by index, or by the value of a key path when
+// the array is listed in `DiffOptions.ArrayKeys` (see `differ.compare`).
+func (d *differ) compareArrays(path, pattern string, x, y Context) {
+	_, xs := diffMembers(x)
+	_, ys := diffMembers(y)
+	elementPattern := joinPath(pattern, "#")
+	keyPath, keyed := d.opts.ArrayKeys[pattern]
+	if len(pattern) == 0 && !keyed {
+		keyPath, keyed = d.opts.ArrayKeys["@this"]
+	}
+	if !keyed {
+		for i := 0; i < len(xs) || i < len(ys); i++ {
+			var a, b Context
+			if i < len(xs) {
+				a = xs[i]
+			}
+			if i < len(ys) {
+				b = ys[i]
+			}
+			d.compare(joinPath(path, strconv.Itoa(i)), elementPattern, a, b)
+		}
+		return
+	}
+	pending := make(map[string][]int)
+	for j, element := range ys {
+		key := diffKey(element.Get(keyPath))
+		pending[key] = append(pending[key], j)
+	}
+	matched := make([]bool, len(ys))
+	for i, element := range xs {
+		key := diffKey(element.Get(keyPath))
+		var other Context
+		if queue := pending[key]; len(queue) > 0 {
+			other = ys[queue[0]]
+			matched[queue[0]] = true
+			pending[key] = queue[1:]
+		}
+		d.compare(joinPath(path, strconv.Itoa(i)), elementPattern, element, other)
+	}
+	for j, element := range ys {
+		if !matched[j] {
+			d.compare(joinPath(path, strconv.Itoa(j)), elementPattern, Context{}, element)
+		}
+	}
+}
+
+// report records a change, using "@this" as the path of the root values.
+func (d *differ) report(path string, kind ChangeKind, x, y Context) {
+	if len(path) == 0 {
+		path = "@this"
+	}
+	d.changes = append(d.changes, Change{Path: path, Kind: kind, Old: x, New: y})
+}
+
+// diffMembers returns the keys and values of the members of an object (keeping the first member of
+// duplicated keys), or the elements of an array with empty keys, with their index in the document.
+func diffMembers(ctx Context) (keys []string, values []Context) {
+	seen := make(map[string]bool)
+	ctx.Foreach(func(key, value Context) bool {
+		if ctx.IsObject() {
+			if seen[key.String()] {
+				return true
+			}
+			seen[key.String()] = true
+		}
+		keys = append(keys, key.String())
+		values = append(values, value)
+		return true
+	})
+	return keys, values
+}
+
+// diffCategory returns the name of the JSON type of a value for a diff, where true and false are
+// both booleans, and objects and arrays are distinguished.
+func diffCategory(ctx Context) string {
+	switch {
+	case ctx.kind == True || ctx.kind == False:
+		return "Boolean"
+	case ctx.IsObject():
+		return "Object"
+	case ctx.IsArray():
+		return "Array"
+	}
+	return ctx.kind.String()
+}
+
+// diffKey returns a canonical representation of the key of an array element, so that keys that are
+// semantically equal (e.g. `1` and `1.0`) match.
+func diffKey(ctx Context) string {
+	switch ctx.kind {
+	case Number:
+		return "n:" + strconv.FormatFloat(ctx.numeric, 'g', -1, 64)
+	case String:
+		return "s:" + ctx.strings
+	case JSON:
+		return "j:" + ctx.unprocessed
+	}
+	if !ctx.Exists() {
+		return ""
+	}
+	return ctx.kind.String()
+}
+
+// collectAggregateNumbers collects the numbers aggregated by the `@sum`, `@avg`, `@min`, `@max` and
+// `@count` transformers from a JSON array.
+//
+// Parameters:
+//   - `json`: The JSON array to aggregate.
+//   - `arg`: An optional JSON object with a `path` field, the path of the value in each element of the
+//     array, and a `strict` field, which rejects non-numeric values instead of ignoring them.
+//
+// Returns:
+//   - `numbers`: The numeric values, in the order of the array.
+//   - `count`: The number of values, numeric or not. Elements without a value at `path` are not counted.
+//   - `ok`: `false` if the input is not an array, or if a value is not a number in strict mode.
+func collectAggregateNumbers(json, arg string) (numbers []Context, count int, ok bool) {
+	ctx := Parse(json)
+	if !ctx.IsArray() {
+		return nil, 0, false
+	}
+	var path string
+	var strict bool
+	Parse(arg).Foreach(func(key, value Context) bool {
+		switch key.String() {
+		case "path":
+			path = value.String()
+		case "strict":
+			strict = value.Bool()
+		}
+		return true
+	})
+	ok = true
+	ctx.Foreach(func(_, value Context) bool {
+		if path != "" {
+			value = value.Get(path)
+		}
+		if !value.Exists() {
+			return true
+		}
+		count++
+		if value.kind != Number {
+			ok = !strict
+			return ok
+		}
+		numbers = append(numbers, value)
+		return true
+	})
+	if !ok {
+		return nil, 0, false
+	}
+	return numbers, count, true
+}
+
+// extremeAggregateNumber returns the number of a JSON array that is preferred over all the others
+// by `better`, as it is written in the array, for the `@min` and `@max` transformers.
+//
+// Parameters:
+//   - `json`: The JSON array to aggregate.
+//   - `arg`: An optional JSON object with the `path` and `strict` fields (see `collectAggregateNumbers`).
+//   - `better`: Reports whether the first number is preferred over the second one.
+//
+// Returns:
+//   - The preferred number, `null` for an array without numbers, or an empty string on failure.
+func extremeAggregateNumber(json, arg string, better func(a, b float64) bool) string {
+	numbers, _, ok := collectAggregateNumbers(json, arg)
+	if !ok {
+		return ""
+	}
+	if len(numbers) == 0 {
+		return "null"
+	}
+	best := numbers[0]
+	for _, n := range numbers[1:] {
+		if better(n.Numeric(), best.Numeric()) {
+			best = n
+		}
+	}
+	return best.unprocessed
+}
+
+// numberDecimals returns the number of decimal places of a JSON number as it is written, or -1 if
+// the number uses an exponent.
+//
+// Example Usage:
+//
+//	numberDecimals("95.85") // 2
+//	numberDecimals("37")    // 0
+//	numberDecimals("1e-3")  // -1
+func numberDecimals(raw string) int {
+	if strings.ContainsAny(raw, "eE") {
+		return -1
+	}
+	if i := strings.IndexByte(raw, '.'); i >= 0 {
+		return len(raw) - i - 1
+	}
+	return 0
+}
+
+// formatAggregateNumber formats the result of a numeric aggregation as a JSON number.
+//
+// Parameters:
+//   - `f`: The number.
+//   - `decimals`: The number of decimal places to round to, or -1 to round to 15 significant digits,
+//     the precision that a float64 always preserves.
+//
+// Returns:
+//   - The JSON number, without trailing zeros in its decimal places, or `null` if the number is not
+//     finite. Numbers of at least 1e21 in magnitude, or below 1e-6 without a number of decimal places,
+//     are written with an exponent (e.g. `2e+300`), as JavaScript does.
+//
+// Example Usage:
+//
+//	formatAggregateNumber(0.30000000000000004, 1)  // "0.3"
+//	formatAggregateNumber(453.1, 2)                // "453.1"
+//	formatAggregateNumber(0.20000000000000004, -1) // "0.2"
+//	formatAggregateNumber(2e300, -1)               // "2e+300"
+func formatAggregateNumber(f float64, decimals int) string {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "null"
+	}
+	if decimals < 0 {
+		f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	}
+	var s string
+	if abs := math.Abs(f); abs >= 1e21 || decimals < 0 && abs != 0 && abs < 1e-6 {
+		s = strconv.FormatFloat(f, 'g', -1, 64)
+	} else {
+		s = strconv.FormatFloat(f, 'f', decimals, 64)
+	}
+	if decimals > 0 {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+// parseSortKeys parses the `by` argument of the `@sort` transformer into sort keys.
+//
+// The argument is either a single path, or an array whose items are paths or objects with a `path`
+// and an optional `desc` field, which overrides the default direction of that key.
+//
+// Parameters:
+//   - `by`: The `by` argument.
+//   - `desc`: The default direction of the keys.
+//
+// Returns:
+//   - The sort keys, in order of precedence. Without a `by` argument, the elements themselves are the
+//     only key.
+//
+// Example Usage:
+//
+//	keys := parseSortKeys(Parse(`["company",{"path":"age","desc":true}]`), false)
+//	// keys: [{company false} {age true}]
+func parseSortKeys(by Context, desc bool) []sortKey {
+	if !by.IsArray() {
+		return []sortKey{{path: by.String(), desc: desc}}
+	}
+	var keys []sortKey
+	by.Foreach(func(_, value Context) bool {
+		key := sortKey{path: value.String(), desc: desc}
+		if value.IsObject() {
+			key.path = value.Get("path").String()
+			if d := value.Get("desc"); d.Exists() {
+				key.desc = d.Bool()
+			}
+		}
+		keys = append(keys, key)
+		return true
+	})
+	if len(keys) == 0 {
+		keys = append(keys, sortKey{desc: desc})
+	}
+	return keys
+}
+
+// compareSortValues compares two values for the `@sort` transformer.
+//
+// Values of different types, and values that are neither strings nor numbers, are ordered as by
+// `Context.Less`: Null < False < Number < String < True < JSON. A missing value is ordered as `null`.
+//
+// Parameters:
+//   - `a`, `b`: The values to compare.
+//   - `natural`: Whether strings are compared in natural order, where runs of digits are compared by
+//     their numeric value (e.g., "item2" before "item10").
+//   - `caseSensitive`: Whether strings are compared with case sensitivity.
+//
+// Returns:
+//   - A negative number if `a` is ordered before `b`, a positive number if it is ordered after, or 0
+//     if they are equivalent.
+func compareSortValues(a, b Context, natural, caseSensitive bool) int {
+	if natural && a.kind == String && b.kind == String {
+		return compareNatural(a.strings, b.strings, caseSensitive)
+	}
+	if a.Less(b, caseSensitive) {
+		return -1
+	}
+	if b.Less(a, caseSensitive) {
+		return 1
+	}
+	return 0
+}
+
+// compareNatural compares two strings in natural order: runs of ASCII digits are compared by their
+// numeric value, and the other characters one by one.
+//
+// Parameters:
+//   - `a`, `b`: The strings to compare.
+//   - `caseSensitive`: Whether the other characters are compared with case sensitivity.
+//
+// Returns:
+//   - A negative number if `a` is ordered before `b`, a positive number if it is ordered after, or 0
+//     if they are equivalent.
+//
+// Example Usage:
+//
+//	compareNatural("file2.txt", "file10.txt", true) // -1
+//	compareNatural("File2", "file2", false)         // 0
+func compareNatural(a, b string, caseSensitive bool) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigitASCII(a[i]) && isDigitASCII(b[j]) {
+			si, sj := i, j
+			for i < len(a) && isDigitASCII(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigitASCII(b[j]) {
+				j++
+			}
+			x := strings.TrimLeft(a[si:i], "0")
+			y := strings.TrimLeft(b[sj:j], "0")
+			if len(x) != len(y) {
+				return len(x) - len(y)
+			}
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+			continue
+		}
+		x, y := a[i], b[j]
+		if !caseSensitive {
+			x, y = toLowerASCII(x), toLowerASCII(y)
+		}
+		if x != y {
+			return int(x) - int(y)
+		}
+		i++
+		j++
+	}
+	return (len(a) - i) - (len(b) - j)
+}
+
+// toLowerASCII converts an ASCII uppercase letter to lowercase, and returns any other byte as is.
+func toLowerASCII(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// isDigitASCII reports whether a byte is an ASCII digit.
+func isDigitASCII(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// distinctElements removes the elements of a JSON array whose key is semantically equal to the key
+// of an earlier element, for the `@unique` and `@distinctBy` transformers.
+//
+// Keys are compared with `jsonEqual`, so `1` and `1.0`, or objects whose keys are in a different
+// order, are duplicates. Elements without a value at the key path all share the same missing key.
+// The keys are bucketed by the hash of their canonical form (see `appendCanonicalJSON`), so that
+// each key is only compared with the earlier keys of its bucket.
+//
+// Parameters:
+//   - `json`: The JSON array to de-duplicate.
+//   - `path`: The path of the key in each element, or "" to compare the elements themselves.
+//
+// Returns:
+//   - The JSON array with the first element of each key, in their original order, or an empty string
+//     if the input is not an array.
+//
+// Example Usage:
+//
+//	distinctElements(`[1,"a",1.0,{"x":1,"y":2},{"y":2,"x":1}]`, "") // [1,"a",{"x":1,"y":2}]
+func distinctElements(json, path string) string {
+	ctx := Parse(json)
+	if !ctx.IsArray() {
+		return ""
+	}
+	seed := maphash.MakeSeed()
+	buckets := make(map[uint64][]Context)
+	var canonical []byte
+	var kept int
+	out := make([]byte, 0, len(json))
+	out = append(out, '[')
+	ctx.Foreach(func(_, value Context) bool {
+		key := value
+		if path != "" {
+			key = value.Get(path)
+		}
+		canonical = appendCanonicalJSON(canonical[:0], key)
+		sum := maphash.Bytes(seed, canonical)
+		for _, k := range buckets[sum] {
+			if jsonEqual(k, key) {
+				return true
+			}
+		}
+		buckets[sum] = append(buckets[sum], key)
+		if kept > 0 {
+			out = append(out, ',')
+		}
+		kept++
+		out = append(out, value.unprocessed...)
+		return true
+	})
+	out = append(out, ']')
+	return unsafeBytesToString(out)
+}
+
+// matchesQuery reports whether an array element matches the query of a path component such as
+// `#(age>30)`, whether it is a single comparison or a logical expression.
+//
+// Parameters:
+//   - `analysis`: The analyzed path component, whose query is active.
+//   - `elem`: The array element.
+//   - `plan`: The compiled path being evaluated, or nil.
+//
+// Returns:
+//   - A boolean indicating whether the element matches the query.
+func matchesQuery(analysis *metadata, elem Context, plan *pathPlan) bool {
+	if analysis.query.Conditions != nil {
+		return analysis.query.Conditions.matches(elem, plan)
+	}
+	res := elem
+	if analysis.query.QueryPath == "" && isQueryLiteralOperator(analysis.query.Option) {
+		// a word operator without a path compares the element itself, objects and arrays included
+		// (e.g. `#(contains 2)` on an array of arrays).
+	} else if elem.kind == JSON {
+		res = elem.get(analysis.query.QueryPath, plan)
+	} else if analysis.query.QueryPath != "" {
+		return false
+	}
+	if analysis.query.ValuePath != "" {
+		other := resolveQueryField(elem, analysis.query.ValuePath, plan)
+		return matchesField(analysis.query.Option, res, other)
+	}
+	return matchesQueryConditions(analysis, res)
+}
+
+// appendCanonicalJSON appends the canonical form of a JSON value to a byte slice: compact JSON where
+// numbers are written by value (e.g., `1.0` and `1e0` as `1`), strings are re-encoded, and the
+// members of objects are sorted by key. Values that are equal by `jsonEqual` have the same canonical
+// form, so it can be hashed to bucket values before comparing them.
+//
+// Parameters:
+//   - `dst`: The byte slice to append to.
+//   - `ctx`: The JSON value. A value that does not exist is written as `null`.
+//
+// Returns:
+//   - The extended byte slice.
+//
+// Example Usage:
+//
+//	appendCanonicalJSON(nil, Parse(`{"b":[1.0, "x"], "a":-0}`)) // {"a":0,"b":[1,"x"]}
+func appendCanonicalJSON(dst []byte, ctx Context) []byte {
+	switch ctx.kind {
+	case True:
+		return append(dst, "true"...)
+	case False:
+		return append(dst, "false"...)
+	case Number:
+		if ctx.numeric == 0 {
+			return append(dst, '0')
+		}
+		return append(dst, ctx.String()...)
+	case String:
+		return appendJSON(dst, ctx.strings)
+	case JSON:
+		if ctx.IsArray() {
+			dst = append(dst, '[')
+			for i, value := range ctx.Array() {
+				if i > 0 {
+					dst = append(dst, ',')
+				}
+				dst = appendCanonicalJSON(dst, value)
+			}
+			return append(dst, ']')
+		}
+		members := ctx.Map()
+		keys := make([]string, 0, len(members))
+		for key := range members {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dst = append(dst, '{')
+		for i, key := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSON(dst, key)
+			dst = append(dst, ':')
+			dst = appendCanonicalJSON(dst, members[key])
+		}
+		return append(dst, '}')
+	}
+	return append(dst, "null"...)
+}
+
+// appendGroup appends the JSON array of the elements of a group of the `@groupBy` transformer to a
+// byte slice, with the elements as they are written in the input.
+func appendGroup(dst []byte, values []Context) []byte {
+	dst = append(dst, '[')
+	for i, value := range values {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, value.unprocessed...)
+	}
+	return append(dst, ']')
+}
+
+// isSliceLike reports whether a path starts with a bracketed component that can only be meant as
+// a slice (see `parseSliceSelector`): a component holding a colon, and none of the characters of
+// the paths, transformers and literals of a multi-selector.
+//
+// Parameters:
+//   - `path`: The path, starting with '['.
+//
+// Returns:
+//   - A boolean indicating whether the component is a slice, valid or not.
+//
+// Example Usage:
+//
+//	isSliceLike("[1:3]|#.name") // true
+//	isSliceLike("[a:b]")        // true (an invalid slice)
+//	isSliceLike("[a,b.c]")      // false (a multi-selector)
+func isSliceLike(path string) bool {
+	end := strings.IndexByte(path, ']')
+	if len(path) == 0 || path[0] != '[' || end < 0 {
+		return false
+	}
+	inner := path[1:end]
+	return strings.IndexByte(inner, ':') >= 0 && !strings.ContainsAny(inner, ".,|@!#*?\\\"([{")
+}
+
+// originPath returns the fj path of a value computed by a deep wildcard (`**`) path, such as the
+// length of an array, made of the path of the value it was computed from and the path that
+// computes it.
+//
+// Parameters:
+//   - `json`: The original JSON.
+//   - `index`: The index of the value the result was computed from.
+//   - `origin`: The path that computes the result from that value (e.g. "#").
+//
+// Returns:
+//   - The fj path of the result (e.g. "tags.#"), or an empty string if the value cannot be located.
+//
+// Example Usage:
+//
+//	originPath(`{"tags":["a","b"]}`, 8, "#") // "tags.#"
+func originPath(json string, index int, origin string) string {
+	components, ok := locateComponents(Context{index: index}, json)
+	if !ok {
+		return ""
+	}
+	var path []byte
+	for _, component := range components {
+		path = append(path, escapeUnsafeChars(component)...)
+		path = append(path, '.')
+	}
+	return string(append(path, origin...))
+}
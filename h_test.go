@@ -715,7 +715,9 @@ func TestVerifyNumeric(t *testing.T) {
 		// Valid numbers
 		{[]byte("123"), 1, 3, true},
 		{[]byte("-123"), 1, 4, true},
-		{[]byte("0"), 1, 1, false},
+		{[]byte("0"), 1, 1, true},
+		{[]byte("7"), 1, 1, true},
+		{[]byte("-7"), 1, 2, true},
 		{[]byte("-0"), 1, 2, true},
 		{[]byte("123.456"), 1, 7, true},
 		{[]byte("-123.456"), 1, 8, true},
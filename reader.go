@@ -59,13 +59,13 @@ func BufioRead(in io.Reader) (string, error) {
 	scanner := bufio.NewReader(in)
 	for {
 		line, err := scanner.ReadString('\n')
+		lines.WriteString(line)
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 			return "", err
 		}
-		lines.WriteString(line)
 	}
 	return lines.String(), nil
 }
@@ -126,13 +126,15 @@ func BufioReadN(in io.Reader) ([]string, error) {
 	scanner := bufio.NewReader(in)
 	for {
 		line, err := scanner.ReadString('\n')
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 			return lines, err
 		}
-		lines = append(lines, line)
 	}
 	return lines, nil
 }
@@ -390,3 +392,197 @@ func TeeProcess(in io.Reader, out io.Writer) (string, error) {
 	}
 	return lines.String(), nil
 }
+
+// ForeachLine iterates over the records of JSON Lines input read from an io.Reader, invoking the
+// callback with the line number and the parsed `Context` of each record.
+//
+// Unlike `Foreach` and the `..` path prefix, which require the entire document in memory, this
+// function reads the input line by line, so memory usage is bounded by the size of the longest line
+// (at most `DefaultMaxLineSize` bytes) regardless of the size of the input.
+//
+// Parameters:
+//   - `r`: An `io.Reader` providing the JSON Lines input, such as a file or a network stream.
+//   - `fn`: The callback invoked for each record, with the 1-based line number and the parsed `Context`.
+//     Returning false stops the iteration.
+//
+// Returns:
+//   - nil if the input was read successfully, or the iteration was stopped by the callback.
+//   - A `*LineError` naming the line number if a record is malformed (wrapping a `*SyntaxError`) or
+//     exceeds the maximum line size (wrapping `ErrLineTooLong`).
+//   - Any other error returned by the reader.
+//
+// Notes:
+//   - Blank lines are skipped, but still counted in the line numbers.
+//   - Trailing carriage returns are removed, so CRLF line endings are supported.
+//   - Use `ForeachLineWithOptions` to change the maximum line size, apply an fj path to each record,
+//     or skip malformed records.
+//
+// Example Usage:
+//
+//	file, err := os.Open("export.jsonl")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer file.Close()
+//
+//	err = ForeachLine(file, func(lineNo int, ctx Context) bool {
+//	    fmt.Println(lineNo, ctx.Get("name").String())
+//	    return true
+//	})
+//	if err != nil {
+//	    log.Fatal(err) // e.g. fj: line 42: fj: invalid JSON at line 1, column 9 (offset 8): ...
+//	}
+func ForeachLine(r io.Reader, fn func(lineNo int, ctx Context) bool) error {
+	return ForeachLineWithOptions(r, LineOptions{}, fn)
+}
+
+// ForeachLineWithOptions iterates over the records of JSON Lines input read from an io.Reader,
+// like `ForeachLine`, with the behavior configured by `LineOptions`.
+//
+// Parameters:
+//   - `r`: An `io.Reader` providing the JSON Lines input.
+//   - `opts`: The options controlling the maximum line size, the path applied to each record and
+//     whether malformed records are skipped.
+//   - `fn`: The callback invoked for each record, with the 1-based line number and the parsed `Context`
+//     (or the result of `opts.Path` on the record). Returning false stops the iteration.
+//
+// Returns:
+//   - nil if the input was read successfully, or the iteration was stopped by the callback.
+//   - A `*PathError` if `opts.Path` is malformed, before any input is read.
+//   - A `*LineError` naming the line number of a malformed or oversized record.
+//   - Any other error returned by the reader.
+//
+// Example Usage:
+//
+//	opts := LineOptions{Path: "user.email", MaxLineSize: 4 << 20, SkipInvalid: true}
+//	err := ForeachLineWithOptions(file, opts, func(lineNo int, ctx Context) bool {
+//	    if ctx.Exists() {
+//	        fmt.Println(lineNo, ctx.String())
+//	    }
+//	    return true
+//	})
+func ForeachLineWithOptions(r io.Reader, opts LineOptions, fn func(lineNo int, ctx Context) bool) error {
+	var path *Path
+	if len(opts.Path) > 0 {
+		var err error
+		if path, err = Compile(opts.Path); err != nil {
+			return err
+		}
+	}
+	maxLineSize := opts.MaxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, min(maxLineSize, 64*1024)), maxLineSize)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimRight(scanner.Bytes(), "\r")
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if _, ok := verifyJSON(line, 0); !ok {
+			if opts.SkipInvalid {
+				continue
+			}
+			return &LineError{Line: lineNo, Err: newSyntaxError(line)}
+		}
+		var ctx Context
+		if path != nil {
+			ctx = path.GetBytes(line)
+		} else {
+			ctx = Parse(string(line))
+		}
+		if !fn(lineNo, ctx) {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return &LineError{Line: lineNo + 1, Err: ErrLineTooLong}
+		}
+		return err
+	}
+	return nil
+}
+
+// NewArrayStream creates an `ArrayStream` that reads the elements of the JSON array read from an
+// io.Reader one at a time.
+//
+// Only the element being read is buffered in memory, so arrays that are much larger than the
+// available memory (e.g. `[{...},{...},...]` exports) can be processed element by element.
+//
+// Parameters:
+//   - `r`: An `io.Reader` providing a JSON document whose root value is an array.
+//
+// Returns:
+//   - A new `ArrayStream` positioned before the first element.
+//
+// Example Usage:
+//
+//	stream := NewArrayStream(file)
+//	for {
+//	    ctx, err := stream.Next()
+//	    if err == io.EOF {
+//	        break
+//	    }
+//	    if err != nil {
+//	        log.Fatal(err)
+//	    }
+//	    fmt.Println(ctx.Get("name").String())
+//	}
+func NewArrayStream(r io.Reader) *ArrayStream {
+	return &ArrayStream{r: r}
+}
+
+// NewArrayStreamPath creates an `ArrayStream` that reads the elements of the JSON array located at
+// a path within the document read from an io.Reader.
+//
+// The stream descends to the array while reading, skipping the values that precede it one at a time,
+// so the document is never loaded into memory as a whole.
+//
+// Parameters:
+//   - `r`: An `io.Reader` providing the JSON document.
+//   - `path`: A simple fj path to the array, made of keys and array indexes (e.g. "data.items").
+//     Wildcards, queries, transformers, multi-selectors and pipes are not supported.
+//
+// Returns:
+//   - A new `ArrayStream` positioned before the first element of the array. An unsupported path is
+//     reported by the first call to `Next`.
+//
+// Example Usage:
+//
+//	stream := NewArrayStreamPath(strings.NewReader(`{"data":{"total":2,"items":[1,2]}}`), "data.items")
+//	ctx, _ := stream.Next() // ctx.Int64(): 1
+//	ctx, _ = stream.Next()  // ctx.Int64(): 2
+//	_, err := stream.Next() // err: io.EOF
+func NewArrayStreamPath(r io.Reader, path string) *ArrayStream {
+	return &ArrayStream{r: r, path: path}
+}
+
+// Next reads the next element of the array.
+//
+// Returns:
+//   - The element as a `Context`. Its `Index` is the byte offset of the element in the stream.
+//   - io.EOF once every element has been read.
+//   - A `*SyntaxError` if the input is not valid JSON, with its offset, line and column counted from
+//     the start of the stream.
+//   - An error wrapping `ErrNotExist` if the path of the stream does not exist, `ErrNotArray` if the
+//     value is not an array, and `ErrUnsupportedPath` if the path is not a simple path.
+//   - Any other error returned by the reader.
+//
+// Notes:
+//   - Errors are sticky: once Next returns an error, every subsequent call returns the same error.
+//   - The input that follows the end of the array is not read.
+func (s *ArrayStream) Next() (Context, error) {
+	if s.err != nil {
+		return Context{}, s.err
+	}
+	ctx, err := s.next()
+	if err != nil {
+		s.err = err
+		return Context{}, err
+	}
+	return ctx, nil
+}
@@ -2,6 +2,8 @@ package fj
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/sivaosorg/unify4g"
@@ -1306,3 +1308,409 @@ func transformPadRight(json, arg string) string {
 	v := t + strings.Repeat(padding, length-len(t))
 	return v
 }
+
+// transformSum computes the sum of the numbers in a JSON array.
+//
+// This function adds up the numeric elements of the input array, or, when a `path` argument is
+// given, the numbers found at that path in each element of the array. The result is a JSON number,
+// so it can be piped into further path components.
+//
+// Parameters:
+//   - `json`: The JSON array to aggregate.
+//   - `arg`: An optional JSON object with the following fields:
+//   - `path`: The path of the number in each element (e.g., `"price_2007"`).
+//   - `strict`: When `true`, non-numeric values make the result empty instead of being ignored.
+//
+// Returns:
+//   - The sum as a JSON number, `0` for an array without numbers, or an empty string if the input
+//     is not an array or, in strict mode, contains a non-numeric value.
+//
+// Example Usage:
+//
+//	json := `[{"price":1.5},{"price":2.25},{"price":"n/a"}]`
+//	result := transformSum(json, `{"path":"price"}`)
+//	fmt.Println(result) // Output: 3.75
+//
+// Notes:
+//   - The sum is rounded to the largest number of decimal places of its operands, so that the
+//     floating-point representation error does not show in the result (e.g., 0.1 + 0.2 gives 0.3).
+//     When an operand uses an exponent (e.g., `1e-3`), the sum is rounded to 15 significant digits
+//     instead, and very large or very small sums are written with an exponent (e.g., `2e+300`).
+func transformSum(json, arg string) string {
+	numbers, _, ok := collectAggregateNumbers(json, arg)
+	if !ok {
+		return ""
+	}
+	var sum float64
+	decimals := 0
+	for _, n := range numbers {
+		sum += n.Numeric()
+		if d := numberDecimals(n.unprocessed); d < 0 || decimals < 0 {
+			decimals = -1
+		} else {
+			decimals = max(decimals, d)
+		}
+	}
+	return formatAggregateNumber(sum, decimals)
+}
+
+// transformAvg computes the arithmetic mean of the numbers in a JSON array.
+//
+// The numbers are collected as for `transformSum`, with the same `path` and `strict` arguments.
+//
+// Parameters:
+//   - `json`: The JSON array to aggregate.
+//   - `arg`: An optional JSON object with the `path` and `strict` fields (see `transformSum`).
+//
+// Returns:
+//   - The mean as a JSON number, `null` for an array without numbers, or an empty string if the
+//     input is not an array or, in strict mode, contains a non-numeric value.
+//
+// Example Usage:
+//
+//	json := `[1, 2, "x", 4]`
+//	result := transformAvg(json, "")
+//	fmt.Println(result) // Output: 2.33333333333333
+//
+// Notes:
+//   - The mean is rounded to 15 significant digits, the precision that a float64 always preserves,
+//     so that the representation error of the sum and the division does not show in the result
+//     (e.g., the mean of 0.1, 0.2 and 0.3 gives 0.2 rather than 0.20000000000000004). Unlike the
+//     sum, the mean can have more decimal places than its operands, so it is not rounded to them.
+func transformAvg(json, arg string) string {
+	numbers, _, ok := collectAggregateNumbers(json, arg)
+	if !ok {
+		return ""
+	}
+	if len(numbers) == 0 {
+		return "null"
+	}
+	var sum float64
+	for _, n := range numbers {
+		sum += n.Numeric()
+	}
+	return formatAggregateNumber(sum/float64(len(numbers)), -1)
+}
+
+// transformMin returns the smallest number in a JSON array.
+//
+// The numbers are collected as for `transformSum`, with the same `path` and `strict` arguments.
+// The number is returned as it is written in the input JSON.
+//
+// Parameters:
+//   - `json`: The JSON array to aggregate.
+//   - `arg`: An optional JSON object with the `path` and `strict` fields (see `transformSum`).
+//
+// Returns:
+//   - The smallest number, `null` for an array without numbers, or an empty string if the input is
+//     not an array or, in strict mode, contains a non-numeric value.
+//
+// Example Usage:
+//
+//	json := `[{"price":44.28},{"price":15.59}]`
+//	result := transformMin(json, `{"path":"price"}`)
+//	fmt.Println(result) // Output: 15.59
+func transformMin(json, arg string) string {
+	return extremeAggregateNumber(json, arg, func(a, b float64) bool { return a < b })
+}
+
+// transformMax returns the largest number in a JSON array.
+//
+// The numbers are collected as for `transformSum`, with the same `path` and `strict` arguments.
+// The number is returned as it is written in the input JSON.
+//
+// Parameters:
+//   - `json`: The JSON array to aggregate.
+//   - `arg`: An optional JSON object with the `path` and `strict` fields (see `transformSum`).
+//
+// Returns:
+//   - The largest number, `null` for an array without numbers, or an empty string if the input is
+//     not an array or, in strict mode, contains a non-numeric value.
+//
+// Example Usage:
+//
+//	json := `[3, 10, 7]`
+//	result := transformMax(json, "")
+//	fmt.Println(result) // Output: 10
+func transformMax(json, arg string) string {
+	return extremeAggregateNumber(json, arg, func(a, b float64) bool { return a > b })
+}
+
+// transformCount counts the values of a JSON array.
+//
+// Without arguments, every element is counted. With a `path` argument, only the elements that have
+// a value at that path are counted. With `strict`, a non-numeric value makes the result empty, as
+// for the other aggregation transformers.
+//
+// Parameters:
+//   - `json`: The JSON array to count.
+//   - `arg`: An optional JSON object with the `path` and `strict` fields (see `transformSum`).
+//
+// Returns:
+//   - The count as a JSON number, or an empty string if the input is not an array or, in strict
+//     mode, contains a non-numeric value.
+//
+// Example Usage:
+//
+//	json := `[{"eyeColor":"blue"},{"eyeColor":null},{}]`
+//	result := transformCount(json, `{"path":"eyeColor"}`)
+//	fmt.Println(result) // Output: 2
+func transformCount(json, arg string) string {
+	_, count, ok := collectAggregateNumbers(json, arg)
+	if !ok {
+		return ""
+	}
+	return strconv.Itoa(count)
+}
+
+// transformSort sorts the elements of a JSON array.
+//
+// The elements are ordered by the values found at one or more key paths, or by their own values when
+// no key is given. Values of different types are ordered as by `Context.Less`: null, false, numbers,
+// strings, true, then objects and arrays. The sort is stable, so elements with equivalent keys keep
+// their original order.
+//
+// Parameters:
+//   - `json`: The JSON array to sort.
+//   - `arg`: An optional JSON object with the following fields:
+//   - `by`: The path of the key in each element (e.g., `"price_2007"`), or an array of keys in order
+//     of precedence, each a path or an object with a `path` and its own `desc` field.
+//   - `desc`: When `true`, the keys are ordered from the greatest to the smallest value.
+//   - `natural`: When `true`, strings are compared in natural order, so "item2" comes before "item10".
+//   - `case_sensitive`: When `false`, strings are compared without case sensitivity. Defaults to `true`.
+//
+// Returns:
+//   - The sorted JSON array, with its elements as they are written in the input, or an empty string if
+//     the input is not an array.
+//
+// Example Usage:
+//
+//	json := `[{"symbol":"MMM","price":95.85},{"symbol":"F","price":8.37},{"symbol":"GIS","price":28.76}]`
+//	result := transformSort(json, `{"by":"price","desc":true}`)
+//	fmt.Println(result) // Output: [{"symbol":"MMM","price":95.85},{"symbol":"GIS","price":28.76},{"symbol":"F","price":8.37}]
+//
+// Notes:
+//   - An element without a value at a key path is ordered as if the value were `null`.
+func transformSort(json, arg string) string {
+	ctx := Parse(json)
+	if !ctx.IsArray() {
+		return ""
+	}
+	var by Context
+	var desc, natural bool
+	caseSensitive := true
+	Parse(arg).Foreach(func(key, value Context) bool {
+		switch key.String() {
+		case "by":
+			by = value
+		case "desc":
+			desc = value.Bool()
+		case "natural":
+			natural = value.Bool()
+		case "case_sensitive":
+			caseSensitive = value.Bool()
+		}
+		return true
+	})
+	keys := parseSortKeys(by, desc)
+	var values []Context
+	var rows [][]Context
+	ctx.Foreach(func(_, value Context) bool {
+		row := make([]Context, len(keys))
+		for i, k := range keys {
+			if k.path == "" {
+				row[i] = value
+			} else {
+				row[i] = value.Get(k.path)
+			}
+		}
+		values = append(values, value)
+		rows = append(rows, row)
+		return true
+	})
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := rows[order[i]], rows[order[j]]
+		for k, key := range keys {
+			c := compareSortValues(a[k], b[k], natural, caseSensitive)
+			if c == 0 {
+				continue
+			}
+			if key.desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+	out := make([]byte, 0, len(json))
+	out = append(out, '[')
+	for i, idx := range order {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = append(out, values[idx].unprocessed...)
+	}
+	out = append(out, ']')
+	return unsafeBytesToString(out)
+}
+
+// transformUnique removes the duplicate elements of a JSON array.
+//
+// Elements are compared by semantic JSON equality: numbers by value, strings after unescaping, and
+// objects member by member regardless of the order of their keys. The first occurrence of each
+// element is kept, and the original order is preserved.
+//
+// Parameters:
+//   - `json`: The JSON array to de-duplicate.
+//   - `arg`: Unused.
+//
+// Returns:
+//   - The JSON array without duplicates, with its elements as they are written in the input, or an
+//     empty string if the input is not an array.
+//
+// Example Usage:
+//
+//	json := `[1, "a", 1.0, {"x":1,"y":2}, {"y":2,"x":1}, "a"]`
+//	result := transformUnique(json, "")
+//	fmt.Println(result) // Output: [1,"a",{"x":1,"y":2}]
+func transformUnique(json, arg string) string {
+	return distinctElements(json, "")
+}
+
+// transformDistinctBy removes the elements of a JSON array whose value at a key path duplicates
+// the value of an earlier element.
+//
+// Key values are compared by semantic JSON equality, as in `transformUnique`. The first element of
+// each key value is kept, and the original order is preserved. Elements without a value at the key
+// path are treated as having the same key.
+//
+// Parameters:
+//   - `json`: The JSON array to de-duplicate.
+//   - `arg`: A JSON object with the following field:
+//   - `path`: The path of the key in each element (e.g., `"company"`). Without it, the elements
+//     themselves are compared, as in `transformUnique`.
+//
+// Returns:
+//   - The JSON array with the first element of each key value, or an empty string if the input is not
+//     an array.
+//
+// Example Usage:
+//
+//	json := `[{"name":"Ann","gender":"female"},{"name":"Bob","gender":"male"},{"name":"Cat","gender":"female"}]`
+//	result := transformDistinctBy(json, `{"path":"gender"}`)
+//	fmt.Println(result) // Output: [{"name":"Ann","gender":"female"},{"name":"Bob","gender":"male"}]
+func transformDistinctBy(json, arg string) string {
+	var path string
+	Parse(arg).Foreach(func(key, value Context) bool {
+		if key.String() == "path" {
+			path = value.String()
+		}
+		return true
+	})
+	return distinctElements(json, path)
+}
+
+// transformGroupBy groups the elements of a JSON array by the value at a key path.
+//
+// The result is a JSON object with a member for each distinct key value, in order of first
+// appearance, whose value is the array of the elements with that key, in their original order.
+// Unlike `transformGroup`, which zips parallel arrays into objects, this is SQL-style grouping.
+//
+// Parameters:
+//   - `json`: The JSON array to group.
+//   - `arg`: A JSON object with the following fields:
+//   - `path`: The path of the key in each element (e.g., `"gender"`). Without it, the elements
+//     themselves are the keys.
+//   - `count`: When `true`, each group is replaced by the number of its elements.
+//   - `aggregate`: An optional path evaluated on the array of each group, whose result replaces the
+//     group (e.g., `"#.age|@avg"`). A group for which the path has no result becomes `null`.
+//
+// Returns:
+//   - The JSON object of the groups, or an empty string if the input is not an array.
+//
+// Example Usage:
+//
+//	json := `[{"name":"Ann","gender":"female"},{"name":"Bob","gender":"male"},{"name":"Cat","gender":"female"}]`
+//	result := transformGroupBy(json, `{"path":"gender"}`)
+//	fmt.Println(result) // Output: {"female":[{"name":"Ann","gender":"female"},{"name":"Cat","gender":"female"}],"male":[{"name":"Bob","gender":"male"}]}
+//
+//	result = transformGroupBy(json, `{"path":"gender","count":true}`)
+//	fmt.Println(result) // Output: {"female":2,"male":1}
+//
+// Notes:
+//   - A string key is the name of its group, and any other key is named by its canonical JSON form
+//     (see `appendCanonicalJSON`), so keys that are equal as for `@unique` share a group: `1.0` is
+//     grouped under "1", and objects are grouped regardless of the order of their members and of
+//     whitespace. Elements whose key is `null` or missing are grouped under "null".
+//   - Unlike `@unique`, a string key shares its group with a non-string key whose JSON text it
+//     equals, such as `"1"` and `1`, since member names are strings.
+//   - When both `count` and `aggregate` are given, `count` takes precedence.
+func transformGroupBy(json, arg string) string {
+	ctx := Parse(json)
+	if !ctx.IsArray() {
+		return ""
+	}
+	var path, aggregate string
+	var count bool
+	Parse(arg).Foreach(func(key, value Context) bool {
+		switch key.String() {
+		case "path":
+			path = value.String()
+		case "count":
+			count = value.Bool()
+		case "aggregate":
+			aggregate = value.String()
+		}
+		return true
+	})
+	var names []string
+	groups := make(map[string][]Context)
+	ctx.Foreach(func(_, value Context) bool {
+		key := value
+		if path != "" {
+			key = value.Get(path)
+		}
+		name := key.strings
+		if key.kind != String {
+			name = string(appendCanonicalJSON(nil, key))
+		}
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], value)
+		return true
+	})
+	out := make([]byte, 0, len(json))
+	out = append(out, '{')
+	for i, name := range names {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = appendJSON(out, name)
+		out = append(out, ':')
+		values := groups[name]
+		if count {
+			out = strconv.AppendInt(out, int64(len(values)), 10)
+			continue
+		}
+		if aggregate == "" {
+			out = appendGroup(out, values)
+			continue
+		}
+		size := 2
+		for _, value := range values {
+			size += len(value.unprocessed) + 1
+		}
+		if result := GetBytes(appendGroup(make([]byte, 0, size), values), aggregate); result.Exists() {
+			out = append(out, result.unprocessed...)
+		} else {
+			out = append(out, "null"...)
+		}
+	}
+	out = append(out, '}')
+	return unsafeBytesToString(out)
+}
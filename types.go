@@ -1,6 +1,8 @@
 package fj
 
 import (
+	"io"
+	"reflect"
 	"unsafe"
 )
 
@@ -32,6 +34,11 @@ type Context struct {
 	// indexes holds the indices of all elements that match a path containing the '#' query character.
 	indexes []int
 
+	// origins holds, for the elements of an array built by a deep wildcard (`**`) path, the value found in
+	// the original JSON that each element was computed from, so that the elements that are not found in
+	// the original JSON themselves (such as the length of an array) can still be located.
+	origins []valueOrigin
+
 	// err stores any error encountered during processing or parsing of the JSON element.
 	// This field is used to capture issues such as invalid JSON syntax, type mismatches,
 	// or any other error that may occur while retrieving or interpreting the JSON value.
@@ -39,6 +46,16 @@ type Context struct {
 	err error
 }
 
+// valueOrigin locates a value computed by a path, such as the length of an array, through the value
+// found in the original JSON that it was computed from.
+type valueOrigin struct {
+	// index is the position of the value found in the original JSON.
+	index int
+
+	// path is the path that computes the value from it (e.g. "#"), or empty if the value is not computed.
+	path string
+}
+
 // queryContext is a simplified version of the Context struct,
 // primarily used to store intermediate results for JSON path processing.
 type queryContext struct {
@@ -123,9 +140,43 @@ type metadata struct {
 
 		// Value represents the value to search for or match in the query.
 		Value string
+
+		// ValuePath is the path of the field the value is compared with, when the right-hand
+		// side of the query is a relative reference such as `@.price_2002`.
+		ValuePath string
+
+		// Conditions holds the parsed expression of a query that combines comparisons with
+		// `&&`, `||`, `!` or parentheses. It is nil for a query made of a single comparison.
+		Conditions *queryCondition
 	}
 }
 
+// queryCondition is a node of the expression tree of a query that combines comparisons
+// with the logical operators `&&`, `||` and `!`, and parentheses.
+type queryCondition struct {
+	// op is the logical operator of the node: "&&", "||", "!", or "" for a single comparison.
+	op string
+
+	// left is the operand of a "!" node, or the left operand of a "&&" or "||" node.
+	left *queryCondition
+
+	// right is the right operand of a "&&" or "||" node.
+	right *queryCondition
+
+	// path is the path of the compared value, relative to the array element.
+	path string
+
+	// option is the comparison operator (e.g., `=`, `!=`, `<`), empty for an existence check.
+	option string
+
+	// value is the unquoted value the compared value is matched against.
+	value string
+
+	// valuePath is the path of the field the compared value is matched against, when the
+	// right-hand side is a relative reference such as `@.price_2002`.
+	valuePath string
+}
+
 // parser holds the state and configuration for parsing JSON data.
 type parser struct {
 	// json is the raw JSON string that needs to be parsed.
@@ -145,6 +196,10 @@ type parser struct {
 
 	// lines indicates whether the JSON data should be processed line by line.
 	lines bool
+
+	// plan holds the pre-parsed segments of a compiled path, or nil when the path is parsed
+	// while it is evaluated.
+	plan *pathPlan
 }
 
 // stringHeader is a custom struct that mimics the reflect.stringHeader type
@@ -169,3 +224,311 @@ type subSelector struct {
 	name string // name represents the name of the selector or key in the JSON path.
 	path string //  path represents the full path expression for the selector.
 }
+
+// pathComponent represents a single segment of a simple fj path (a path made only of
+// object keys and array indexes), as used by the functions that modify a JSON document.
+type pathComponent struct {
+	// key is the unescaped text of the segment.
+	key string
+
+	// index is the numeric value of the segment when it is a non-negative integer,
+	// or -1 when the segment cannot be used as an array index.
+	index int
+
+	// append indicates that the segment is `-1`, which appends a new element to an array.
+	append bool
+
+	// escaped indicates that the segment contained at least one escaped character,
+	// which forces it to be treated as an object key when a new container is created.
+	escaped bool
+}
+
+// byteSpan represents a half-open range of byte offsets `[start, end)` within a JSON document.
+type byteSpan struct {
+	start int // The offset of the first byte of the range.
+	end   int // The offset just past the last byte of the range.
+}
+
+// unmarshalField describes a struct field that can be populated by `Context.Unmarshal`.
+type unmarshalField struct {
+	// name is the JSON object key matched by the field: its `json` tag name, or the Go field
+	// name when the field has no tag.
+	name string
+
+	// path is the fj path taken from the `fj` tag, relative to the object being decoded.
+	// When it is set, the field is populated with the result of the path instead of `name`.
+	path string
+
+	// index is the index sequence of the field, as used by `reflect.Value.FieldByIndex`.
+	index []int
+}
+
+// TypeError describes a value that could not be converted to the Go type requested from `As` or
+// `GetAs`, either because the value does not exist or because its JSON type does not match.
+type TypeError struct {
+	// Path is the fj path that was evaluated, or an empty string when the value was converted
+	// directly from a Context.
+	Path string
+
+	// Kind is the JSON type of the value. It is meaningless when Missing is true.
+	Kind Type
+
+	// Target is the Go type that was requested.
+	Target reflect.Type
+
+	// Missing indicates that the value does not exist.
+	Missing bool
+
+	// Err is the underlying decoding error reported for a nested value of a composite target
+	// (struct, map, slice, array or pointer), or nil.
+	Err error
+}
+
+// Path represents a compiled fj path, created by `Compile`.
+//
+// A Path parses its path string once, so that evaluating it against many JSON documents skips
+// the parsing of keys, queries, transformers and multi-selectors. A Path is safe for concurrent
+// use by multiple goroutines.
+type Path struct {
+	// path is the source path string.
+	path string
+
+	// plan holds the pre-parsed segments of the path.
+	plan *pathPlan
+
+	// transformers records whether transformers were enabled (see `DisableTransformers`) when
+	// the path was compiled, since the meaning of '@' depends on it.
+	transformers bool
+}
+
+// pathPlan holds the pre-parsed segments of a compiled path, keyed by their location within the
+// source path string. Every path string that the evaluation functions receive while walking a
+// document is a substring of the source, so a segment can be found from the offset and length
+// of the string in the source.
+type pathPlan struct {
+	// source is the source path string.
+	source string
+
+	// suffixes holds the segments that extend to the end of the source, indexed by offset.
+	suffixes []*compiledSegment
+
+	// inner holds the other segments, keyed by their byte span in the source.
+	inner map[byteSpan]*compiledSegment
+}
+
+// compiledSegment holds the results of the path-parsing functions for one substring of a
+// compiled path.
+type compiledSegment struct {
+	// wildcard is the result of `parsePathWithTransformers`.
+	wildcard wildcard
+
+	// analysis is the result of `analyzePath`, and partIdx the array index of its Part,
+	// or -1 when the Part is not an index.
+	analysis metadata
+	partIdx  int
+
+	// left, right and split are the results of `splitPathPipe`.
+	left, right string
+	split       bool
+
+	// transformer holds the transformer that starts the segment, or nil.
+	transformer *compiledTransformer
+
+	// pathStatic, static and staticOk are the results of `parseStaticSegment`.
+	pathStatic, static string
+	staticOk           bool
+
+	// selectors, selectorsOut and selectorsOk are the results of `analyzeSubSelectors`.
+	selectors    []subSelector
+	selectorsOut string
+	selectorsOk  bool
+}
+
+// compiledTransformer is a transformer resolved by `parseTransformer`.
+type compiledTransformer struct {
+	fn        func(json, arg string) string // The registered transformer function.
+	args      string                        // The raw argument of the transformer.
+	pathYield string                        // The remaining path after the transformer.
+}
+
+// PathError describes a syntax error in an fj path, as reported by `Compile`, `ValidatePath`,
+// and by `Get` through `Context.IsError` and `Context.ErrMessage`.
+type PathError struct {
+	// Path is the path that contains the error.
+	Path string
+
+	// Offset is the byte offset of the offending component within Path.
+	Offset int
+
+	// Reason is a short description of the error, such as "unbalanced query".
+	Reason string
+}
+
+// SyntaxError describes the location and the cause of a JSON syntax error, as reported by
+// `Validate` and `ValidateBytes`.
+type SyntaxError struct {
+	// Offset is the byte offset of the error in the input.
+	Offset int
+
+	// Line is the 1-based line number of the error.
+	Line int
+
+	// Column is the 1-based column number of the error, counted in characters (runes).
+	Column int
+
+	// Expected describes the token that was expected at Offset, such as "':'" or "value".
+	Expected string
+
+	// Found describes the character found at Offset, or "end of input".
+	Found string
+
+	// Excerpt is a short portion of the line around the error.
+	Excerpt string
+}
+
+// LineOptions configures how `ForeachLineWithOptions` reads JSON Lines input.
+type LineOptions struct {
+	// MaxLineSize is the maximum size, in bytes, of a single line. Longer lines stop the iteration
+	// with a `*LineError` wrapping `ErrLineTooLong`. Zero means `DefaultMaxLineSize`.
+	MaxLineSize int
+
+	// Path is an optional fj path applied to each line; the callback receives its result instead of
+	// the whole record. The result may not exist when the path does not match a record.
+	Path string
+
+	// SkipInvalid skips malformed records instead of stopping the iteration with a `*LineError`.
+	SkipInvalid bool
+}
+
+// LineError describes an error on a specific line of JSON Lines input, as reported by `ForeachLine`.
+type LineError struct {
+	// Line is the 1-based line number of the record.
+	Line int
+
+	// Err is the underlying error, such as a `*SyntaxError` or `ErrLineTooLong`.
+	Err error
+}
+
+// ArrayStream reads the elements of a JSON array from an io.Reader one at a time, so that arrays
+// larger than the available memory can be processed. It is created by `NewArrayStream` and
+// `NewArrayStreamPath`, and its elements are read with `Next`.
+type ArrayStream struct {
+	r       io.Reader // The underlying reader.
+	path    string    // The simple fj path of the array within the document, or "" for the root.
+	buf     []byte    // The buffered input, starting at the current position of the stream.
+	offset  int       // The offset of buf[0] in the stream.
+	line    int       // The number of newlines before buf[0].
+	column  int       // The number of characters between the last newline and buf[0].
+	eof     bool      // Whether the underlying reader reached EOF.
+	started bool      // Whether the stream has reached the opening bracket of the array.
+	count   int       // The number of elements read so far.
+	err     error     // The sticky error returned by Next, or io.EOF once the array is exhausted.
+}
+
+// jsonPathQuery is a parsed RFC 9535 JSONPath query: an absolute query that starts at the root
+// (`$`), or a relative query that starts at the current node of a filter (`@`).
+type jsonPathQuery struct {
+	relative bool              // Whether the query starts at the current node (`@`) instead of the root (`$`).
+	segments []jsonPathSegment // The segments applied in order to the start node.
+}
+
+// jsonPathSegment is a child segment (`.name`, `[...]`) or a descendant segment (`..name`, `..[...]`)
+// of a JSONPath query.
+type jsonPathSegment struct {
+	descendant bool               // Whether the selectors apply to the node and all of its descendants.
+	selectors  []jsonPathSelector // The selectors of the segment, whose results are concatenated.
+}
+
+// jsonPathSelector selects the children of a node in a JSONPath segment.
+type jsonPathSelector struct {
+	kind   byte          // One of 'n' (name), '*' (wildcard), 'i' (index), ':' (slice) and '?' (filter).
+	name   string        // The member name of a name selector.
+	index  int           // The index of an index selector, which may be negative.
+	slice  [3]*int       // The optional start, end and step of a slice selector.
+	filter *jsonPathExpr // The logical expression of a filter selector.
+}
+
+// jsonPathExpr is a node of the logical expression of a JSONPath filter selector.
+type jsonPathExpr struct {
+	op          string           // One of "||", "&&", "!", "exists", or a comparison operator (e.g. "==", "<=").
+	left, right *jsonPathExpr    // The operands of "||" and "&&", or the operand of "!" (left).
+	query       *jsonPathQuery   // The query of an existence test.
+	operands    [2]jsonPathValue // The operands of a comparison.
+}
+
+// jsonPathValue is an operand of a JSONPath comparison: a literal, or a singular query.
+type jsonPathValue struct {
+	literal Context        // The literal value, when query is nil.
+	query   *jsonPathQuery // The singular query that produces the value.
+}
+
+// jsonPathNode is a value selected by a JSONPath query, together with its normalized path.
+type jsonPathNode struct {
+	value Context // The selected value, with its index in the original JSON.
+	path  string  // The normalized path of the value (e.g. $['store']['book'][0]).
+}
+
+// jsonPathParser parses RFC 9535 JSONPath expressions (see `JSONPath`).
+type jsonPathParser struct {
+	expr string // The expression being parsed.
+	pos  int    // The offset of the next character to parse.
+}
+
+// PatchError describes a JSON Patch operation that could not be applied by `ApplyPatch`.
+type PatchError struct {
+	// Index is the 0-based index of the operation within the patch document.
+	Index int
+
+	// Op is the name of the operation, such as "test" or "remove".
+	Op string
+
+	// Err is the underlying error, such as `ErrTestFailed` or `ErrNotExist`.
+	Err error
+}
+
+// ChangeKind represents the kind of a `Change` reported by `Diff`.
+type ChangeKind int
+
+// Change describes a difference between two JSON documents, as reported by `Diff`.
+type Change struct {
+	// Path is the fj path of the value, as produced by `Context.Path` (e.g. "bank.0.email"), or
+	// "@this" for the root value. Array indexes refer to the first document, except for added values.
+	Path string
+
+	// Kind is the kind of the change.
+	Kind ChangeKind
+
+	// Old is the value in the first document, which does not exist for added values.
+	Old Context
+
+	// New is the value in the second document, which does not exist for removed values.
+	New Context
+}
+
+// DiffOptions configures how `DiffWithOptions` compares two JSON documents.
+type DiffOptions struct {
+	// IgnoreKeyOrder ignores the order of the keys of objects. Otherwise, an object whose common keys
+	// appear in a different order is reported as changed as a whole, without the changes of its members.
+	IgnoreKeyOrder bool
+
+	// ArrayKeys treats arrays as sets whose elements are matched by the value at a key path, instead
+	// of by their index. It maps the path of an array, where "#" stands for any array index (e.g.
+	// "bank" or "users.#.roles"), to the path of the key within its elements (e.g. "email").
+	ArrayKeys map[string]string
+
+	// Tolerance is the maximum absolute difference between two numbers that are considered equal.
+	Tolerance float64
+}
+
+// differ accumulates the changes found by `DiffWithOptions` while it walks two JSON documents.
+type differ struct {
+	opts    DiffOptions // The options of the comparison.
+	changes []Change    // The changes found so far, in document order.
+}
+
+// sortKey is a key of the `@sort` transformer: the value at a path in each element of the array,
+// and the direction in which it is ordered.
+type sortKey struct {
+	path string // The path of the value in each element, or "" for the element itself.
+	desc bool   // Whether the key is ordered from the greatest to the smallest value.
+}